@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	adaptiveFloor    = 100 * time.Millisecond
+	adaptiveIncrease = 2.0
+	adaptiveDecrease = 0.9
+)
+
+// AdaptiveDelay is a delay shared by all workers that doubles on every
+// failed check and eases back by 10% on every success, staying within
+// [min, max]. Errors from IsOnWhatsApp are usually rate limiting, so
+// backing off quickly and recovering slowly keeps long scans alive.
+type AdaptiveDelay struct {
+	mu      sync.Mutex
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func NewAdaptiveDelay(min, max time.Duration) *AdaptiveDelay {
+	if max < min {
+		max = min
+	}
+	return &AdaptiveDelay{min: min, max: max, current: min}
+}
+
+func (a *AdaptiveDelay) Delay() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.current
+}
+
+func (a *AdaptiveDelay) Failure() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	next := time.Duration(float64(a.current) * adaptiveIncrease)
+	if next < adaptiveFloor {
+		next = adaptiveFloor
+	}
+	if next > a.max {
+		next = a.max
+	}
+	a.current = next
+	return a.current
+}
+
+func (a *AdaptiveDelay) Success() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	next := time.Duration(float64(a.current) * adaptiveDecrease)
+	if next < a.min {
+		next = a.min
+	}
+	a.current = next
+	return a.current
+}
+
+// maxTargetWorkers caps the workers -target-rate will start.
+const maxTargetWorkers = 32
+
+// targetRateParams picks a worker count and per-worker delay that together
+// make about rate requests per second. Each worker keeps at least minGap
+// (the configured -delay plus half of -jitter, on average) between its own
+// requests, so higher rates are reached with more workers rather than by
+// hammering from one.
+func targetRateParams(rate float64, minGap, jitter time.Duration) (workers int, delay time.Duration) {
+	workers = int(math.Ceil(rate * minGap.Seconds()))
+	workers = min(max(workers, 1), maxTargetWorkers)
+	delay = time.Duration(float64(workers)/rate*float64(time.Second)) - jitter/2
+	return workers, max(delay, 0).Round(time.Millisecond)
+}
+
+// delayWindow turns -min-delay/-max-delay into the -delay and -jitter that
+// make every wait uniformly random within [lo, hi). A zero hi means a fixed
+// lo.
+func delayWindow(lo, hi time.Duration) (delay, jitter time.Duration, err error) {
+	if hi == 0 {
+		hi = lo
+	}
+	if lo < 0 {
+		return 0, 0, fmt.Errorf("-min-delay can't be negative")
+	}
+	if lo > hi {
+		return 0, 0, fmt.Errorf("-min-delay %s is longer than -max-delay %s", lo, hi)
+	}
+	return lo, hi - lo, nil
+}
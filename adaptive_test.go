@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveDelay(t *testing.T) {
+	const ms = time.Millisecond
+	a := NewAdaptiveDelay(50*ms, 1*time.Second)
+	steps := []struct {
+		fail bool
+		want time.Duration
+	}{
+		// The first failure jumps straight to the floor.
+		{true, 100 * ms},
+		{true, 200 * ms},
+		{true, 400 * ms},
+		{false, 360 * ms},
+		{true, 720 * ms},
+		{true, 1 * time.Second},
+		{true, 1 * time.Second},
+		{false, 900 * ms},
+	}
+	for i, step := range steps {
+		var got time.Duration
+		if step.fail {
+			got = a.Failure()
+		} else {
+			got = a.Success()
+		}
+		if got != step.want || a.Delay() != step.want {
+			t.Fatalf("step %d (fail=%v): delay %s, want %s", i, step.fail, got, step.want)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		a.Success()
+	}
+	if got := a.Delay(); got != 50*ms {
+		t.Errorf("after a long run of successes: delay %s, want the minimum 50ms", got)
+	}
+}
+
+func TestAdaptiveDelayBounds(t *testing.T) {
+	// max below min is raised to min.
+	a := NewAdaptiveDelay(300*time.Millisecond, time.Millisecond)
+	if got := a.Failure(); got != 300*time.Millisecond {
+		t.Errorf("Failure = %s, want 300ms", got)
+	}
+	if got := a.Success(); got != 300*time.Millisecond {
+		t.Errorf("Success = %s, want 300ms", got)
+	}
+}
+
+func TestTargetRateParams(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		rate        float64
+		minGap      time.Duration
+		jitter      time.Duration
+		wantWorkers int
+		wantDelay   time.Duration
+	}{
+		// One worker can manage 1/s with a 500ms minimum gap.
+		{1, 500 * ms, 0, 1, time.Second},
+		{10, 500 * ms, 0, 5, 500 * ms},
+		{10, 500 * ms, 200 * ms, 5, 400 * ms},
+		{3, time.Second, 0, 3, time.Second},
+		// A zero gap still starts one worker.
+		{4, 0, 0, 1, 250 * ms},
+		// More than maxTargetWorkers would be needed: the delay shrinks.
+		{100, time.Second, 0, maxTargetWorkers, 320 * ms},
+		// Jitter longer than the gap can't make the delay negative.
+		{10, 0, time.Second, 1, 0},
+	}
+	for _, tt := range tests {
+		workers, delay := targetRateParams(tt.rate, tt.minGap, tt.jitter)
+		if workers != tt.wantWorkers || delay != tt.wantDelay {
+			t.Errorf("targetRateParams(%g, %s, %s) = %d workers, %s; want %d, %s",
+				tt.rate, tt.minGap, tt.jitter, workers, delay, tt.wantWorkers, tt.wantDelay)
+		}
+	}
+}
+
+func TestDelayWindow(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		lo, hi        time.Duration
+		delay, jitter time.Duration
+		wantErr       bool
+	}{
+		{300 * ms, 1200 * ms, 300 * ms, 900 * ms, false},
+		{300 * ms, 0, 300 * ms, 0, false},
+		{0, 500 * ms, 0, 500 * ms, false},
+		{500 * ms, 500 * ms, 500 * ms, 0, false},
+		{1200 * ms, 300 * ms, 0, 0, true},
+		{-ms, 300 * ms, 0, 0, true},
+	}
+	for _, tt := range tests {
+		delay, jitter, err := delayWindow(tt.lo, tt.hi)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("delayWindow(%s, %s): err %v, want error %v", tt.lo, tt.hi, err, tt.wantErr)
+			continue
+		}
+		if delay != tt.delay || jitter != tt.jitter {
+			t.Errorf("delayWindow(%s, %s) = %s, %s, want %s, %s", tt.lo, tt.hi, delay, jitter, tt.delay, tt.jitter)
+		}
+	}
+}
+
+func TestDelayWindowSamples(t *testing.T) {
+	lo, hi := 300*time.Millisecond, 1200*time.Millisecond
+	delay, jitter, err := delayWindow(lo, hi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if d := delay + jitterDelay(jitter); d < lo || d >= hi {
+			t.Fatalf("sampled %s, want within [%s, %s)", d, lo, hi)
+		}
+	}
+}
+
+func TestDelayWindowFlags(t *testing.T) {
+	dryRunNumbers(t, "-delay", "5s", "-min-delay", "300ms", "-max-delay", "1200ms", "15550000001")
+	if *delay != 300*time.Millisecond || *jitter != 900*time.Millisecond {
+		t.Errorf("-delay 5s with a window: delay %s, jitter %s, want the window to win", *delay, *jitter)
+	}
+	if code := runArgs(t, "-dry-run", "-min-delay", "2s", "-max-delay", "1s", "15550000001"); code != exitUsage {
+		t.Errorf("-min-delay > -max-delay: exit code %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+var jpegBytes = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01fake jpeg")
+
+func TestDownloadFile(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write(jpegBytes) })
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	setFlag(t, &httpClient, newHTTPClient(nil, 100*time.Millisecond, nil))
+	dir := t.TempDir()
+
+	path, _, err := downloadFile(ts.URL+"/ok", filepath.Join(dir, "ok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(jpegBytes) {
+		t.Errorf("%s has %q", path, data)
+	}
+
+	if _, _, err := downloadFile(ts.URL+"/missing", filepath.Join(dir, "missing")); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("404: err = %v", err)
+	}
+
+	start := time.Now()
+	if _, _, err := downloadFile(ts.URL+"/slow", filepath.Join(dir, "slow")); err == nil {
+		t.Error("slow download should time out")
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("timeout took %s", elapsed)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("failed downloads left files behind: %v", entries)
+	}
+}
+
+func TestImageExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		head        string
+		contentType string
+		want        string
+	}{
+		{"png magic", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "", ".png"},
+		{"jpeg magic", string(jpegBytes), "", ".jpg"},
+		{"gif magic", "GIF89a\x01\x00\x01\x00", "", ".gif"},
+		{"webp magic", "RIFF\x00\x00\x00\x00WEBPVP8 ", "", ".webp"},
+		{"magic beats header", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/jpeg", ".png"},
+		{"header fallback", "not an image", "image/webp; charset=binary", ".webp"},
+		{"unknown", "not an image", "application/octet-stream", ".jpg"},
+	}
+	for _, tt := range tests {
+		if got := imageExtension([]byte(tt.head), tt.contentType); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadFileUsesDetectedExtension(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// WhatsApp's CDN doesn't always label the body correctly.
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte(png))
+	}))
+	defer ts.Close()
+	setFlag(t, &httpClient, ts.Client())
+
+	path, _, err := downloadFile(ts.URL, filepath.Join(t.TempDir(), "avatar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(path) != ".png" {
+		t.Errorf("saved as %s", path)
+	}
+}
+
+func TestScannerSavesAvatarsUnderAvatarDir(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(jpegBytes) }))
+	defer ts.Close()
+	setFlag(t, &httpClient, ts.Client())
+
+	dir := filepath.Join(t.TempDir(), "avatars", "20260101-120000")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient("15550000001", "15550000002")
+	client.pictures["15550000001"] = &types.ProfilePictureInfo{URL: ts.URL + "/1.jpg", ID: "1"}
+	s := &Scanner{Client: client, Config: ScanConfig{AvatarDir: dir}}
+	results, err := s.Run(context.Background(), []string{"1555000000[1-2]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if res.Phone == "15550000002" {
+			if res.AvatarPath != "" {
+				t.Errorf("no picture, but AvatarPath = %s", res.AvatarPath)
+			}
+			continue
+		}
+		if want := filepath.Join(dir, "15550000001.jpg"); res.AvatarPath != want {
+			t.Errorf("AvatarPath = %s, want %s", res.AvatarPath, want)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "15550000001.jpg" {
+		t.Errorf("avatar dir holds %v", entries)
+	}
+}
+
+func TestAvatarFinalURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v/t61/1.jpg", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/cdn/1.jpg?oh=abc", http.StatusFound)
+	})
+	mux.HandleFunc("/cdn/1.jpg", func(w http.ResponseWriter, r *http.Request) { w.Write(jpegBytes) })
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	setFlag(t, &httpClient, ts.Client())
+	want := ts.URL + "/cdn/1.jpg?oh=abc"
+
+	if got, err := resolveURL(ts.URL + "/v/t61/1.jpg"); err != nil || got != want {
+		t.Errorf("resolveURL = %q, %v, want %q", got, err, want)
+	}
+	_, got, err := downloadFile(ts.URL+"/v/t61/1.jpg", filepath.Join(t.TempDir(), "1"))
+	if err != nil || got != want {
+		t.Errorf("downloadFile final URL = %q, %v, want %q", got, err, want)
+	}
+
+	for name, cfg := range map[string]ScanConfig{
+		"follow-avatar": {FollowAvatar: true},
+		"save-avatars":  {AvatarDir: t.TempDir()},
+	} {
+		client := newFakeClient("15550000001")
+		client.pictures["15550000001"] = &types.ProfilePictureInfo{URL: ts.URL + "/v/t61/1.jpg", ID: "1"}
+		results, err := (&Scanner{Client: client, Config: cfg}).Run(context.Background(), []string{"15550000001"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("%s: got %d results", name, len(results))
+		}
+		res := results[0]
+		if res.AvatarURL != ts.URL+"/v/t61/1.jpg" || res.AvatarFinal != want {
+			t.Errorf("%s: AvatarURL %q, AvatarFinal %q, want the original and %q", name, res.AvatarURL, res.AvatarFinal, want)
+		}
+	}
+}
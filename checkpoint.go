@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Checkpoint struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func loadCheckpoint(path string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		jid := strings.TrimSpace(scanner.Text())
+		if jid != "" {
+			done[jid] = true
+		}
+	}
+	return done, scanner.Err()
+}
+
+func openCheckpoint(path string) (*Checkpoint, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{f: f}, nil
+}
+
+// Record appends a checked JID. Each line goes out in a single write so a
+// crash can at worst lose the last entry, never interleave two of them.
+func (cp *Checkpoint) Record(jid string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	_, err := cp.f.WriteString(jid + "\n")
+	return err
+}
+
+func (cp *Checkpoint) Close() error {
+	return cp.f.Close()
+}
+
+// loadCSVPhones reads the Phone column of an earlier -csv export and returns
+// those numbers as JIDs, for -resume-from-csv. A missing file is an empty
+// one; rows that can't be parsed are skipped and counted in bad.
+func loadCSVPhones(path string) (done map[string]bool, bad int, err error) {
+	done = make(map[string]bool)
+	cr, closeCSV, err := openCSV(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if cr == nil {
+		return done, 0, nil
+	}
+	defer closeCSV()
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return done, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", path, err)
+	}
+	col := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), "Phone") {
+			col = i
+		}
+	}
+	if col < 0 {
+		return nil, 0, fmt.Errorf("%s: no Phone column in header", path)
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			bad++
+			continue
+		}
+		if err != nil {
+			// A truncated gzip stream (e.g. after a crash) still leaves
+			// everything before it usable.
+			bad++
+			break
+		}
+		if col >= len(row) {
+			bad++
+			continue
+		}
+		phone := strings.TrimPrefix(strings.TrimSpace(row[col]), "+")
+		if phone == "" || strings.Trim(phone, "0123456789") != "" {
+			bad++
+			continue
+		}
+		done[phone+"@c.us"] = true
+	}
+	return done, bad, nil
+}
+
+// openCSV opens a CSV export for reading, decompressing .gz files. A missing
+// or empty file gives a nil reader and no error.
+func openCSV(path string) (cr *csv.Reader, closeCSV func(), err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r io.Reader = f
+	closeCSV = func() { f.Close() }
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err == io.EOF {
+			f.Close()
+			return nil, nil, nil
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		r = gz
+		closeCSV = func() {
+			gz.Close()
+			f.Close()
+		}
+	}
+
+	cr = csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	return cr, closeCSV, nil
+}
+
+// checkCSVHeader makes sure a -csv file that is about to be appended to has
+// the columns this version writes, so new rows don't end up under the wrong
+// headings. A missing or empty file is fine.
+func checkCSVHeader(path string) error {
+	cr, closeCSV, err := openCSV(path)
+	if err != nil || cr == nil {
+		return err
+	}
+	defer closeCSV()
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if !equalHeader(header, csvHeader) {
+		return fmt.Errorf("%s doesn't have the columns this version writes (%d, found %d); use a new -csv file", path, len(csvHeader), len(header))
+	}
+	return nil
+}
+
+func equalHeader(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i]) != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestCheckpointSkipsRecordedJIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+	cp, err := openCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, jid := range []string{"15550000001@c.us", "15550000002@c.us", "15550000005@c.us"} {
+		if err := cp.Record(jid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient("15550000001", "15550000003")
+	s := &Scanner{Client: client, Skip: done}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000003"}) {
+		t.Errorf("got %v", got)
+	}
+	for _, req := range client.requests {
+		for _, pn := range req {
+			if done[pn+"@c.us"] {
+				t.Errorf("%s was in the checkpoint but was checked again", pn)
+			}
+		}
+	}
+	if got := client.callCount(); got != 7 {
+		t.Errorf("checked %d numbers, want 7", got)
+	}
+}
+
+func TestLoadCheckpointMissing(t *testing.T) {
+	done, err := loadCheckpoint(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(done) != 0 {
+		t.Errorf("got %v, %v; want an empty set", done, err)
+	}
+}
+
+func TestResumeFromCSVSkipsExported(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.csv")
+	csvData := strings.Join(csvHeader, ",") + "\n" +
+		"15550000001,https://wa.me/15550000001\n" +
+		"not a number,garbage\n" +
+		"\n" +
+		"+15550000002\n"
+	if err := os.WriteFile(path, []byte(csvData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	done, bad, err := loadCSVPhones(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad != 1 || len(done) != 2 || !done["15550000001@c.us"] || !done["15550000002@c.us"] {
+		t.Fatalf("got %v with %d bad rows, want 15550000001, 15550000002 and 1 bad row", done, bad)
+	}
+
+	client := newFakeClient("15550000001", "15550000003")
+	s := &Scanner{Client: client, Skip: done}
+	if got := scanPhones(t, s, "1555000000[1-4]"); !equalStrings(got, []string{"15550000003"}) {
+		t.Errorf("got %v", got)
+	}
+	if got := client.callCount(); got != 2 {
+		t.Errorf("checked %d numbers, want 2", got)
+	}
+
+	noPhone := filepath.Join(dir, "other.csv")
+	os.WriteFile(noPhone, []byte("Number,Name\n15550000001,Alice\n"), 0644)
+	if _, _, err := loadCSVPhones(noPhone); err == nil {
+		t.Error("a CSV without a Phone column should be an error")
+	}
+	if done, _, err := loadCSVPhones(filepath.Join(dir, "missing.csv")); err != nil || len(done) != 0 {
+		t.Errorf("missing CSV: %v, %v", done, err)
+	}
+}
+
+// TestCheckpointRecordsOnlySettledNumbers wires the checkpoint up the way
+// run does: numbers with nothing to export are recorded by the scanner,
+// found ones only once the writer has saved them.
+func TestCheckpointRecordsOnlySettledNumbers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scan.checkpoint")
+	setFlag(t, csvFile, filepath.Join(dir, "out.csv"))
+	cp, err := openCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(jid string) {
+		if err := cp.Record(jid); err != nil {
+			t.Error(err)
+		}
+	}
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.AfterWrite(func(res ScanResult) { record(res.Phone + "@c.us") })
+
+	client := newFakeClient("15550000003", "15550000007")
+	client.numberErrs["15550000002"] = errors.New("server error")
+	var failed []string
+	s := &Scanner{
+		Client:   client,
+		OnDone:   record,
+		OnFailed: func(pn string, err error) { failed = append(failed, pn) },
+	}
+	for res := range s.Start(context.Background(), []string{"1555000000x"}) {
+		// 15550000007 is lost before it reaches the writer, as on a crash.
+		if res.Phone != "15550000007" {
+			w.Write(res)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cp.Close()
+	if !equalStrings(failed, []string{"15550000002"}) {
+		t.Fatalf("failed %v, want 15550000002", failed)
+	}
+
+	done, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if done["15550000002@c.us"] {
+		t.Error("the failed number is in the checkpoint")
+	}
+	if done["15550000007@c.us"] {
+		t.Error("a found number that was never written is in the checkpoint")
+	}
+	if !done["15550000003@c.us"] || len(done) != 8 {
+		t.Errorf("checkpoint holds %v, want the 8 settled numbers", done)
+	}
+
+	// The resumed scan checks exactly the two unsettled numbers again.
+	client = newFakeClient("15550000003", "15550000007")
+	if got := scanPhones(t, &Scanner{Client: client, Skip: done}, "1555000000x"); !equalStrings(got, []string{"15550000007"}) {
+		t.Errorf("resumed scan found %v", got)
+	}
+	var rechecked []string
+	for _, req := range client.requests {
+		rechecked = append(rechecked, req...)
+	}
+	sort.Strings(rechecked)
+	if !equalStrings(rechecked, []string{"15550000002", "15550000007"}) {
+		t.Errorf("resumed scan checked %v", rechecked)
+	}
+}
+
+func TestScannerOnDone(t *testing.T) {
+	client := newFakeClient("15550000003", "15550000004")
+	client.business["15550000004"] = &types.BusinessProfile{}
+	client.numberErrs["15550000005"] = errors.New("server error")
+	var mu sync.Mutex
+	var done []string
+	s := &Scanner{
+		Client: client,
+		Config: ScanConfig{OnlyBusiness: true},
+		OnDone: func(jid string) {
+			mu.Lock()
+			done = append(done, jid)
+			mu.Unlock()
+		},
+	}
+	if got := scanPhones(t, s, "1555000000[2-5]"); !equalStrings(got, []string{"15550000004"}) {
+		t.Fatalf("found %v", got)
+	}
+	// 2 isn't on WhatsApp and 3 is filtered out; 4 is left to whoever
+	// saves the result and 5 failed.
+	sort.Strings(done)
+	if !equalStrings(done, []string{"15550000002@c.us", "15550000003@c.us"}) {
+		t.Errorf("done %v", done)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// colorAllowed reports whether colors may be used at all: not with
+// -no-color or when NO_COLOR is set (https://no-color.org).
+func colorAllowed() bool {
+	return !*noColor && os.Getenv("NO_COLOR") == ""
+}
+
+// colorFor reports whether output written to f should be colored, which it
+// never is when f is piped or redirected.
+func colorFor(f *os.File) bool {
+	return colorAllowed() && term.IsTerminal(int(f.Fd()))
+}
+
+// paint wraps s in an ANSI color when enabled is true.
+func paint(enabled bool, color, s string) string {
+	if !enabled || s == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func printError(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, paint(colorFor(os.Stderr), colorRed, "Error:")+" "+format, args...)
+}
+
+func printWarning(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, paint(colorFor(os.Stderr), colorYellow, "Warning:")+" "+format, args...)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPaint(t *testing.T) {
+	if got := paint(true, colorGreen, "[+] FOUND"); got != "\033[32m[+] FOUND\033[0m" {
+		t.Errorf("enabled: %q", got)
+	}
+	for _, s := range []string{"[+] FOUND", ""} {
+		if got := paint(false, colorGreen, s); got != s {
+			t.Errorf("disabled: %q, want %q unchanged", got, s)
+		}
+	}
+	if got := paint(true, colorRed, ""); got != "" {
+		t.Errorf("empty string painted: %q", got)
+	}
+}
+
+func TestColorDisabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if !colorAllowed() {
+		t.Fatal("color disallowed with no -no-color and no NO_COLOR")
+	}
+	setFlag(t, noColor, true)
+	if colorAllowed() {
+		t.Error("color allowed with -no-color")
+	}
+	*noColor = false
+	t.Setenv("NO_COLOR", "1")
+	if colorAllowed() {
+		t.Error("color allowed with NO_COLOR set")
+	}
+	t.Setenv("NO_COLOR", "")
+
+	// Files and pipes are never colored.
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if colorFor(f) {
+		t.Error("color enabled for a regular file")
+	}
+	_, stderr := captureOutput(t, func() {
+		printError("boom\n")
+		printWarning("careful\n")
+	})
+	if stderr != "Error: boom\nWarning: careful\n" {
+		t.Errorf("piped stderr = %q, want no color codes", stderr)
+	}
+
+	var buf bytes.Buffer
+	p := NewProgress(&buf, true, 2)
+	p.Checked("15550000001")
+	p.Print("[+] FOUND\n")
+	p.Finish()
+	if strings.Contains(buf.String(), "\033[3") {
+		t.Errorf("progress colored with color off: %q", buf.String())
+	}
+	buf.Reset()
+	p = NewProgress(&buf, true, 2)
+	p.color = true
+	p.Checked("15550000001")
+	if !strings.Contains(buf.String(), colorYellow) {
+		t.Errorf("progress not colored with color on: %q", buf.String())
+	}
+}
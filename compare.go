@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// loadResults reads a results file written by -json-file (a JSON array) or
+// -json (NDJSON, one object per line). Numbers reported as not on WhatsApp
+// (-include-not-found) are left out.
+func loadResults(path string) ([]ScanResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stored []storedResult
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &stored); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var res storedResult
+			if err := dec.Decode(&res); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			stored = append(stored, res)
+		}
+	}
+
+	var results []ScanResult
+	for _, res := range stored {
+		if res.Found == nil || *res.Found {
+			res.ScanResult.Found = true
+			results = append(results, res.ScanResult)
+		}
+	}
+	return results, nil
+}
+
+// storedResult tells a missing "found" field, from files written before
+// -include-not-found existed (every result was found), from "found": false.
+type storedResult struct {
+	ScanResult
+	Found *bool `json:"found"`
+}
+
+// avatarIDs maps phone numbers to the avatar ID they had in results.
+func avatarIDs(results []ScanResult) map[string]string {
+	ids := make(map[string]string, len(results))
+	for _, res := range results {
+		ids[strings.TrimPrefix(res.Phone, "+")] = res.AvatarID
+	}
+	return ids
+}
+
+// avatarChanged reports whether res has a different avatar than it had in
+// the baseline. Numbers missing from the baseline don't count as changed.
+func avatarChanged(baseline map[string]string, res ScanResult) bool {
+	old, ok := baseline[strings.TrimPrefix(res.Phone, "+")]
+	return ok && old != res.AvatarID
+}
+
+// FieldChange is one field that differs between two scans of a number.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type ResultChange struct {
+	Phone   string        `json:"phone"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// ResultDiff is what -compare reports between an old and a new scan.
+type ResultDiff struct {
+	Added   []ScanResult   `json:"added"`
+	Removed []ScanResult   `json:"removed"`
+	Changed []ResultChange `json:"changed"`
+}
+
+// diffResults lists numbers found only in newer (added), only in older
+// (removed), and found in both with a different name, status or avatar.
+// Each group is sorted by phone number.
+func diffResults(older, newer []ScanResult) ResultDiff {
+	diff := ResultDiff{Added: []ScanResult{}, Removed: []ScanResult{}, Changed: []ResultChange{}}
+	oldByPhone := make(map[string]ScanResult, len(older))
+	for _, res := range older {
+		oldByPhone[strings.TrimPrefix(res.Phone, "+")] = res
+	}
+	newByPhone := make(map[string]ScanResult, len(newer))
+	for _, res := range newer {
+		newByPhone[strings.TrimPrefix(res.Phone, "+")] = res
+	}
+
+	for phone, res := range newByPhone {
+		old, ok := oldByPhone[phone]
+		if !ok {
+			diff.Added = append(diff.Added, res)
+			continue
+		}
+		var changes []FieldChange
+		for _, f := range []struct{ name, old, new string }{
+			{"name", old.Name, res.Name},
+			{"push_name", old.PushName, res.PushName},
+			{"status", old.Status, res.Status},
+			{"verified_name", old.VerifiedName, res.VerifiedName},
+			{"avatar_id", old.AvatarID, res.AvatarID},
+		} {
+			if f.old != f.new {
+				changes = append(changes, FieldChange{Field: f.name, Old: f.old, New: f.new})
+			}
+		}
+		if len(changes) > 0 {
+			diff.Changed = append(diff.Changed, ResultChange{Phone: phone, Changes: changes})
+		}
+	}
+	for phone, res := range oldByPhone {
+		if _, ok := newByPhone[phone]; !ok {
+			diff.Removed = append(diff.Removed, res)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Phone < diff.Added[j].Phone })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Phone < diff.Removed[j].Phone })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Phone < diff.Changed[j].Phone })
+	return diff
+}
+
+// runCompare prints the diff between two result files, as grouped text or
+// as one JSON object.
+func runCompare(oldPath, newPath string, asJSON bool, w io.Writer) error {
+	older, err := loadResults(oldPath)
+	if err != nil {
+		return err
+	}
+	newer, err := loadResults(newPath)
+	if err != nil {
+		return err
+	}
+	diff := diffResults(older, newer)
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diff)
+	}
+
+	fmt.Fprintf(w, "[+] Added (%d):\n", len(diff.Added))
+	for _, res := range diff.Added {
+		fmt.Fprintf(w, "    %s\n", describeResult(res))
+	}
+	fmt.Fprintf(w, "[-] Removed (%d):\n", len(diff.Removed))
+	for _, res := range diff.Removed {
+		fmt.Fprintf(w, "    %s\n", describeResult(res))
+	}
+	fmt.Fprintf(w, "[~] Changed (%d):\n", len(diff.Changed))
+	for _, c := range diff.Changed {
+		fmt.Fprintf(w, "    %s\n", c.Phone)
+		for _, f := range c.Changes {
+			fmt.Fprintf(w, "        %s: %q -> %q\n", f.Field, f.Old, f.New)
+		}
+	}
+	return nil
+}
+
+func describeResult(res ScanResult) string {
+	if res.Name != "" {
+		return fmt.Sprintf("%s (%s)", res.Phone, res.Name)
+	}
+	return res.Phone
+}
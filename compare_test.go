@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestAvatarChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "previous.json")
+	previous := `[
+		{"phone": "15550000001", "avatar_id": "100"},
+		{"phone": "+15550000002", "avatar_id": "200"},
+		{"phone": "15550000003"}
+	]`
+	if err := os.WriteFile(path, []byte(previous), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old, err := loadResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline := avatarIDs(old)
+
+	client := newFakeClient("15550000001", "15550000002", "15550000003", "15550000004")
+	client.pictures["15550000001"] = &types.ProfilePictureInfo{URL: "https://pps.example/1.jpg", ID: "100"}
+	client.pictures["15550000002"] = &types.ProfilePictureInfo{URL: "https://pps.example/2.jpg", ID: "201"}
+	client.pictures["15550000003"] = &types.ProfilePictureInfo{URL: "https://pps.example/3.jpg", ID: "300"}
+	client.pictures["15550000004"] = &types.ProfilePictureInfo{URL: "https://pps.example/4.jpg", ID: "400"}
+	results, err := (&Scanner{Client: client}).Run(context.Background(), []string{"1555000000[1-4]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		"15550000001": false, // same picture
+		"15550000002": true,  // new picture
+		"15550000003": true,  // had none, now has one
+		"15550000004": false, // not in the baseline
+	}
+	for _, res := range results {
+		if res.AvatarID != client.pictures[res.Phone].ID {
+			t.Errorf("%s: AvatarID %q, want %q", res.Phone, res.AvatarID, client.pictures[res.Phone].ID)
+		}
+		if got := avatarChanged(baseline, res); got != want[res.Phone] {
+			t.Errorf("%s: changed = %v, want %v", res.Phone, got, want[res.Phone])
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("got %d results, want %d", len(results), len(want))
+	}
+}
+
+func writeResults(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCompare(t *testing.T) {
+	// The old scan is a -json-file array, the new one -json NDJSON.
+	oldPath := writeResults(t, "old.json", `[
+		{"phone": "15550000001", "name": "Alice", "status": "hi", "avatar_id": "1"},
+		{"phone": "15550000002", "name": "Bob"},
+		{"phone": "15550000003", "name": "Carol"},
+		{"phone": "15550000005", "found": false}
+	]`)
+	newPath := writeResults(t, "new.ndjson", `{"phone": "15550000001", "name": "Alice", "status": "away", "avatar_id": "2"}
+{"phone": "+15550000003", "name": "Carol"}
+{"phone": "15550000004", "name": "Dave"}
+{"phone": "15550000002", "found": false}
+`)
+
+	var out bytes.Buffer
+	if err := runCompare(oldPath, newPath, true, &out); err != nil {
+		t.Fatal(err)
+	}
+	var diff ResultDiff
+	if err := json.Unmarshal(out.Bytes(), &diff); err != nil {
+		t.Fatalf("-compare-json output: %v\n%s", err, out.String())
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Phone != "15550000004" {
+		t.Errorf("added: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Phone != "15550000002" {
+		t.Errorf("removed: %+v", diff.Removed)
+	}
+	want := []ResultChange{{Phone: "15550000001", Changes: []FieldChange{
+		{Field: "status", Old: "hi", New: "away"},
+		{Field: "avatar_id", Old: "1", New: "2"},
+	}}}
+	if !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("changed: %+v, want %+v", diff.Changed, want)
+	}
+
+	out.Reset()
+	if err := runCompare(oldPath, newPath, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"[+] Added (1):\n    15550000004 (Dave)\n",
+		"[-] Removed (1):\n    15550000002 (Bob)\n",
+		"[~] Changed (1):\n    15550000001\n        status: \"hi\" -> \"away\"\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("text output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestCompareRunsOffline(t *testing.T) {
+	path := writeResults(t, "scan.json", `[{"phone": "15550000001"}]`)
+	session := filepath.Join(t.TempDir(), "wabf.db")
+	stdout, _ := captureOutput(t, func() {
+		if code := runArgs(t, "-compare", "-session", session, path, path); code != exitOK {
+			t.Errorf("exit code %d", code)
+		}
+	})
+	if !strings.Contains(stdout, "[+] Added (0):") {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if _, err := os.Stat(session); !os.IsNotExist(err) {
+		t.Errorf("-compare opened the session database (%v)", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// applyConfigFile loads flag defaults from a TOML file. Keys use the flag
+// names (e.g. concurrency = 4, delay = "500ms"); anything already given on
+// the command line wins. Unknown keys are reported but not fatal.
+func applyConfigFile(fs *flag.FlagSet, path string) ([]string, error) {
+	var values map[string]interface{}
+	if _, err := toml.DecodeFile(path, &values); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	setOnCLI := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setOnCLI[f.Name] = true
+	})
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var warnings []string
+	for _, key := range keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		if name == "config" || fs.Lookup(name) == nil {
+			warnings = append(warnings, fmt.Sprintf("unknown config key %q", key))
+			continue
+		}
+		if setOnCLI[name] {
+			continue
+		}
+		if err := fs.Set(name, fmt.Sprint(values[key])); err != nil {
+			return warnings, fmt.Errorf("invalid value for config key %q: %v", key, err)
+		}
+	}
+	return warnings, nil
+}
+
+// envName is the environment variable that sets a flag, e.g. WABF_OUTPUT_FORMAT
+// for -output-format.
+func envName(flagName string) string {
+	return "WABF_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag not given on the command line from its WABF_*
+// environment variable, if present. Call it before applyConfigFile so the
+// environment wins over the config file.
+func applyEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	setOnCLI := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setOnCLI[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || setOnCLI[f.Name] {
+			return
+		}
+		value, ok := lookup(envName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value for %s: %v", envName(f.Name), setErr)
+		}
+	})
+	return err
+}
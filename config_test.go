@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testFlags struct {
+	fs           *flag.FlagSet
+	concurrency  *int
+	delay        *time.Duration
+	rate         *float64
+	outputFormat *string
+	saveAvatars  *bool
+}
+
+func newTestFlags() testFlags {
+	fs := flag.NewFlagSet("wabf", flag.ContinueOnError)
+	return testFlags{
+		fs:           fs,
+		concurrency:  fs.Int("concurrency", 1, ""),
+		delay:        fs.Duration("delay", 200*time.Millisecond, ""),
+		rate:         fs.Float64("rate", 0, ""),
+		outputFormat: fs.String("output-format", "wa.me", ""),
+		saveAvatars:  fs.Bool("save-avatars", false, ""),
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wabf.toml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	path := writeConfig(t, `
+concurrency = 4
+delay = "500ms"
+rate = 2.5
+output_format = "e164"
+save-avatars = true
+colour = "always"
+`)
+	f := newTestFlags()
+	if err := f.fs.Parse([]string{"-concurrency", "8"}); err != nil {
+		t.Fatal(err)
+	}
+	warnings, err := applyConfigFile(f.fs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0] != `unknown config key "colour"` {
+		t.Errorf("warnings = %v", warnings)
+	}
+	if *f.concurrency != 8 {
+		t.Errorf("concurrency = %d, the command line should win", *f.concurrency)
+	}
+	if *f.delay != 500*time.Millisecond || *f.rate != 2.5 || *f.outputFormat != "e164" || !*f.saveAvatars {
+		t.Errorf("got delay %s, rate %v, output-format %q, save-avatars %v", *f.delay, *f.rate, *f.outputFormat, *f.saveAvatars)
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"bad toml":  "concurrency = ",
+		"bad value": `delay = "soon"`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := applyConfigFile(newTestFlags().fs, writeConfig(t, content)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+	if _, err := applyConfigFile(newTestFlags().fs, filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("missing file: expected an error")
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	for name, want := range map[string]string{
+		"concurrency":   "WABF_CONCURRENCY",
+		"output-format": "WABF_OUTPUT_FORMAT",
+		"save-avatars":  "WABF_SAVE_AVATARS",
+	} {
+		if got := envName(name); got != want {
+			t.Errorf("envName(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"WABF_CONCURRENCY":   "4",
+		"WABF_DELAY":         "500ms",
+		"WABF_OUTPUT_FORMAT": "e164",
+		"WABF_SAVE_AVATARS":  "true",
+		"CONCURRENCY":        "16",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	f := newTestFlags()
+	if err := f.fs.Parse([]string{"-output-format", "pn"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(f.fs, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if *f.concurrency != 4 || *f.delay != 500*time.Millisecond || !*f.saveAvatars {
+		t.Errorf("got concurrency %d, delay %s, save-avatars %v", *f.concurrency, *f.delay, *f.saveAvatars)
+	}
+	if *f.outputFormat != "pn" {
+		t.Errorf("output-format = %q, the command line should win", *f.outputFormat)
+	}
+	if *f.rate != 0 {
+		t.Errorf("rate = %v with no WABF_RATE", *f.rate)
+	}
+
+	// The environment is applied first, so it wins over the config file.
+	if _, err := applyConfigFile(f.fs, writeConfig(t, "concurrency = 8\nrate = 2.5\n")); err != nil {
+		t.Fatal(err)
+	}
+	if *f.concurrency != 4 || *f.rate != 2.5 {
+		t.Errorf("after the config file: concurrency %d, rate %v", *f.concurrency, *f.rate)
+	}
+
+	env = map[string]string{"WABF_DELAY": "soon"}
+	if err := applyEnv(newTestFlags().fs, lookup); err == nil || !strings.Contains(err.Error(), "WABF_DELAY") {
+		t.Errorf("bad value: err %v", err)
+	}
+}
+
+func TestEnvFlags(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "numbers.txt")
+	t.Setenv("WABF_OUTPUT_FORMAT", "e164")
+	if code := runArgs(t, "-dry-run", "-output-file", out, "15550000001"); code != exitOK {
+		t.Fatalf("exit code %d", code)
+	}
+	if data, _ := os.ReadFile(out); strings.TrimSpace(string(data)) != "+15550000001" {
+		t.Errorf("output %q, want WABF_OUTPUT_FORMAT applied", data)
+	}
+
+	t.Setenv("WABF_CONCURRENCY", "many")
+	if code := runArgs(t, "-dry-run", "-output-file", out, "15550000001"); code != exitUsage {
+		t.Errorf("bad WABF_CONCURRENCY: exit code %d, want %d", code, exitUsage)
+	}
+}
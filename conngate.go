@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ConnGate pauses workers while the WhatsApp connection is down. whatsmeow
+// reconnects on its own (with backoff); the gate is closed on
+// events.Disconnected and reopened on events.Connected.
+type ConnGate struct {
+	mu    sync.Mutex
+	ready chan struct{}
+	since time.Time
+}
+
+func NewConnGate() *ConnGate {
+	ready := make(chan struct{})
+	close(ready)
+	return &ConnGate{ready: ready}
+}
+
+// Down marks the connection as lost. It reports whether it was up before.
+func (g *ConnGate) Down() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.ready:
+		g.ready = make(chan struct{})
+		g.since = time.Now()
+		return true
+	default:
+		return false
+	}
+}
+
+// Up marks the connection as restored and returns how long it was down.
+func (g *ConnGate) Up() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-g.ready:
+		return 0
+	default:
+		close(g.ready)
+		return time.Since(g.since)
+	}
+}
+
+// Wait blocks until the connection is up or ctx is done.
+func (g *ConnGate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	ready := g.ready
+	g.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitTimeout is Wait with a limit; it reports false if the connection
+// was still down after d.
+func (g *ConnGate) WaitTimeout(ctx context.Context, d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	return g.Wait(ctx) == nil
+}
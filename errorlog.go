@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrorLog records numbers whose check failed, one per line with the error
+// as a trailing comment, so the file can be fed straight back in with
+// -input-file.
+type ErrorLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func OpenErrorLog(path string) (*ErrorLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ErrorLog{f: f}, nil
+}
+
+func (l *ErrorLog) Record(pn string, checkErr error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	msg := strings.ReplaceAll(checkErr.Error(), "\n", " ")
+	_, err := fmt.Fprintf(l.f, "%s # %s\n", pn, msg)
+	return err
+}
+
+func (l *ErrorLog) Close() error {
+	return l.f.Close()
+}
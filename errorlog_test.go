@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrorLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.txt")
+	errorLog, err := OpenErrorLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeClient("15550000001")
+	client.numberErrs["15550000002"] = errors.New("bad\nnumber")
+	s := &Scanner{
+		Client: client,
+		OnFailed: func(pn string, err error) {
+			if err := errorLog.Record(pn, err); err != nil {
+				t.Error(err)
+			}
+		},
+	}
+	if got := scanPhones(t, s, "1555000000[1-3]"); !equalStrings(got, []string{"15550000001"}) {
+		t.Errorf("found %v", got)
+	}
+	if err := errorLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if want := "15550000002 # bad number\n"; string(data) != want {
+		t.Errorf("errors file:\ngot  %q\nwant %q", data, want)
+	}
+	// The file is meant to be fed back in with -input-file.
+	patterns, _, err := readPatternFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(patterns, []string{"15550000002"}) {
+		t.Errorf("read back %v", patterns)
+	}
+}
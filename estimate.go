@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// estimateScan projects how long checking count numbers takes with the
+// given pacing, ignoring network latency. Each worker waits delay plus on
+// average half the jitter before every request; -rate, if slower, wins.
+// perSecond is the resulting request rate (0 when nothing limits it).
+func estimateScan(count, concurrency, batch int, delay, jitter time.Duration, rate float64) (total time.Duration, requests int, perSecond float64) {
+	concurrency = max(concurrency, 1)
+	batch = max(batch, 1)
+	requests = (count + batch - 1) / batch
+
+	if gap := delay + jitter/2; gap > 0 {
+		perSecond = float64(concurrency) / gap.Seconds()
+	}
+	if rate > 0 && (perSecond == 0 || rate < perSecond) {
+		perSecond = rate
+	}
+	if perSecond > 0 {
+		total = time.Duration(math.Round(float64(requests) / perSecond * float64(time.Second)))
+	}
+	return total, requests, perSecond
+}
+
+// runEstimate counts the numbers patterns expand to and prints the
+// projected scan time without connecting.
+func runEstimate(patterns []string, w io.Writer) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("no pattern provided")
+	}
+	count, invalid := 0, 0
+	err := streamJIDs(patterns, withValidation(func(jid string) bool {
+		count++
+		return true
+	}, &invalid, *validate, *sane))
+	if err != nil {
+		return err
+	}
+
+	total, requests, perSecond := estimateScan(count, *concurrency, *batchSize, *delay, *jitter, *rateLimit)
+	if *validate || *sane {
+		fmt.Fprintf(w, "[-] Filtered %d numbers that are not valid phone numbers.\n", invalid)
+	}
+	fmt.Fprintf(w, "[-] Numbers to check: %d (%d requests with -batch %d)\n", count, requests, max(*batchSize, 1))
+	if perSecond == 0 {
+		fmt.Fprintln(w, "[-] Request rate: unlimited (-delay, -jitter and -rate are all 0), bounded only by network latency")
+		return nil
+	}
+	fmt.Fprintf(w, "[-] Request rate: ~%.2f/s (-concurrency %d, -delay %s, -jitter %s", perSecond, max(*concurrency, 1), *delay, *jitter)
+	if *rateLimit > 0 {
+		fmt.Fprintf(w, ", -rate %g", *rateLimit)
+	}
+	fmt.Fprintln(w, ")")
+	fmt.Fprintf(w, "[-] Estimated time: ~%s (not counting network latency or retries)\n", total.Round(time.Second))
+	return nil
+}
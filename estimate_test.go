@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateScan(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name                      string
+		count, concurrency, batch int
+		delay, jitter             time.Duration
+		rate                      float64
+		wantTotal                 time.Duration
+		wantRequests              int
+		wantPerSecond             float64
+	}{
+		// 1000 requests, 4 workers each one per 500ms+50ms: 1000/(4/0.55s).
+		{"workers", 1000, 4, 1, 500 * ms, 100 * ms, 0, 137500 * ms, 1000, 4 / 0.55},
+		{"batches", 1000, 1, 50, time.Second, 0, 0, 20 * time.Second, 20, 1},
+		{"partial batch", 101, 1, 50, time.Second, 0, 0, 3 * time.Second, 3, 1},
+		{"rate is slower", 100, 8, 1, 100 * ms, 0, 2, 50 * time.Second, 100, 2},
+		{"rate is faster", 100, 1, 1, time.Second, 0, 10, 100 * time.Second, 100, 1},
+		{"rate only", 100, 1, 1, 0, 0, 4, 25 * time.Second, 100, 4},
+		{"unlimited", 100, 0, 0, 0, 0, 0, 0, 100, 0},
+	}
+	for _, tt := range tests {
+		total, requests, perSecond := estimateScan(tt.count, tt.concurrency, tt.batch, tt.delay, tt.jitter, tt.rate)
+		if total != tt.wantTotal || requests != tt.wantRequests || perSecond != tt.wantPerSecond {
+			t.Errorf("%s: got %s, %d requests, %.3f/s; want %s, %d requests, %.3f/s",
+				tt.name, total, requests, perSecond, tt.wantTotal, tt.wantRequests, tt.wantPerSecond)
+		}
+	}
+}
+
+func TestEstimateDoesNotConnect(t *testing.T) {
+	stdout, _ := captureOutput(t, func() {
+		if code := runArgs(t, "-estimate", "-delay", "1s", "-jitter", "0", "-concurrency", "2", "1555xxx"); code != exitOK {
+			t.Errorf("exit code %d", code)
+		}
+	})
+	for _, want := range []string{"Numbers to check: 1000", "~2.00/s", "Estimated time: ~8m20s"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output is missing %q:\n%s", want, stdout)
+		}
+	}
+}
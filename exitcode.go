@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// Exit codes returned by run, so scripts can tell the outcomes apart. A
+// second Ctrl+C still force-quits with 130.
+const (
+	exitOK          = 0 // completed and found at least one account
+	exitError       = 1 // anything else, e.g. an export file that can't be written
+	exitUsage       = 2 // bad flags, patterns or input files
+	exitNoneFound   = 3 // completed but found nothing
+	exitInterrupted = 4 // stopped by Ctrl+C or -timeout
+	exitConnection  = 5 // login failed, or the connection was lost for good
+)
+
+// scanStatus names how a scan ended, from its context error and whether
+// it stopped at -max-found or lost the connection for good.
+func scanStatus(ctxErr error, reachedMax, connLost bool) string {
+	switch {
+	case reachedMax:
+		return "max_found"
+	case connLost:
+		return "connection_lost"
+	case errors.Is(ctxErr, context.DeadlineExceeded):
+		return "timeout"
+	case ctxErr != nil:
+		return "interrupted"
+	}
+	return "finished"
+}
+
+// scanExitCode maps the final scan status (as written to -summary) and
+// the number of accounts found to the exit code.
+func scanExitCode(status string, found int) int {
+	switch status {
+	case "interrupted", "timeout":
+		return exitInterrupted
+	case "connection_lost":
+		return exitConnection
+	}
+	if found == 0 {
+		return exitNoneFound
+	}
+	return exitOK
+}
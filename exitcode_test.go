@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scanner  func(cancel context.CancelFunc) *Scanner
+		timeout  time.Duration
+		connLost bool
+		want     int
+	}{
+		{"found", func(context.CancelFunc) *Scanner {
+			return &Scanner{Client: newFakeClient("15550000001")}
+		}, 0, false, exitOK},
+		{"none found", func(context.CancelFunc) *Scanner {
+			return &Scanner{Client: newFakeClient()}
+		}, 0, false, exitNoneFound},
+		{"max found", func(context.CancelFunc) *Scanner {
+			return &Scanner{Client: newFakeClient("15550000001", "15550000002"), MaxFound: 1}
+		}, 0, false, exitOK},
+		{"interrupted", func(cancel context.CancelFunc) *Scanner {
+			return &Scanner{Client: newFakeClient("15550000001"), OnChecked: func([]string, time.Duration) { cancel() }}
+		}, 0, false, exitInterrupted},
+		{"timeout", func(context.CancelFunc) *Scanner {
+			client := newFakeClient("15550000001")
+			client.delay = 10 * time.Millisecond
+			return &Scanner{Client: client}
+		}, 30 * time.Millisecond, false, exitInterrupted},
+		{"connection lost", func(context.CancelFunc) *Scanner {
+			return &Scanner{Client: newFakeClient("15550000001")}
+		}, 0, true, exitConnection},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+			s := tt.scanner(cancel)
+			results, _ := s.Run(ctx, []string{"1555000000x"})
+			status := scanStatus(ctx.Err(), s.ReachedMax(), tt.connLost)
+			if got := scanExitCode(status, len(results)); got != tt.want {
+				t.Errorf("status %q with %d found: exit code %d, want %d", status, len(results), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageExitCode(t *testing.T) {
+	for _, args := range [][]string{
+		{"-dry-run", "15abc"},
+		{"-dry-run", "-output-format", "fax", "15550000001"},
+		{"-dry-run", "-resume-from-csv", "15550000001"},
+	} {
+		// flag.Parse itself exits with 2 on unknown flags.
+		if code := runArgs(t, args...); code != exitUsage {
+			t.Errorf("%v: exit code %d, want %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waVnameCert"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// fakeClient is an in-memory WAClient. Numbers in registered are on
+// WhatsApp; everything else isn't.
+type fakeClient struct {
+	mu sync.Mutex
+
+	registered map[string]bool
+	verified   map[string]string
+	business   map[string]*types.BusinessProfile
+	status     map[string]string
+	pictures   map[string]*types.ProfilePictureInfo
+	contacts   map[string]types.ContactInfo
+	devices    map[string][]types.JID
+	lids       map[string]string
+	// previews are returned instead of pictures when the preview is asked
+	// for; fullErrs fail requests for the full-size picture.
+	previews map[string]*types.ProfilePictureInfo
+	fullErrs map[string]error
+	// certs replace the verified name with a whole certificate.
+	certs map[string]*types.VerifiedName
+	// aliases maps a number to the account it resolves to, e.g. the same
+	// account reached with and without a trunk zero.
+	aliases map[string]string
+
+	// delay is how long each IsOnWhatsApp call takes.
+	delay time.Duration
+	// checkErrs are returned by the next IsOnWhatsApp calls, one per call.
+	checkErrs []error
+	// batchErr fails every IsOnWhatsApp call with more than one number.
+	batchErr error
+	// numberErrs fails IsOnWhatsApp whenever the number is in the request.
+	numberErrs map[string]error
+	// onCall, if set, runs at the start of every IsOnWhatsApp call.
+	onCall func(phones []string)
+	// enrichDelay is how long each GetBusinessProfile call takes.
+	enrichDelay time.Duration
+	// registerAll puts every number on WhatsApp, for scans too large to
+	// list in registered.
+	registerAll bool
+
+	calls    int
+	requests [][]string
+	inFlight int
+	peak     int
+	// enrichCalls counts business, user info, picture and contact lookups.
+	enrichCalls int
+}
+
+func newFakeClient(registered ...string) *fakeClient {
+	f := &fakeClient{
+		registered: make(map[string]bool),
+		verified:   make(map[string]string),
+		business:   make(map[string]*types.BusinessProfile),
+		status:     make(map[string]string),
+		pictures:   make(map[string]*types.ProfilePictureInfo),
+		previews:   make(map[string]*types.ProfilePictureInfo),
+		fullErrs:   make(map[string]error),
+		certs:      make(map[string]*types.VerifiedName),
+		contacts:   make(map[string]types.ContactInfo),
+		devices:    make(map[string][]types.JID),
+		lids:       make(map[string]string),
+		aliases:    make(map[string]string),
+		numberErrs: make(map[string]error),
+	}
+	for _, pn := range registered {
+		f.registered[pn] = true
+	}
+	return f
+}
+
+func (f *fakeClient) IsOnWhatsApp(ctx context.Context, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+	if f.onCall != nil {
+		f.onCall(phones)
+	}
+	f.mu.Lock()
+	f.calls++
+	f.requests = append(f.requests, append([]string(nil), phones...))
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	if f.delay > 0 {
+		f.mu.Unlock()
+		time.Sleep(f.delay)
+		f.mu.Lock()
+	}
+	if len(f.checkErrs) > 0 {
+		err := f.checkErrs[0]
+		f.checkErrs = f.checkErrs[1:]
+		f.mu.Unlock()
+		return nil, err
+	}
+	if f.batchErr != nil && len(phones) > 1 {
+		f.mu.Unlock()
+		return nil, f.batchErr
+	}
+	var resp []types.IsOnWhatsAppResponse
+	for _, phone := range phones {
+		pn := strings.TrimPrefix(phone, "+")
+		if err := f.numberErrs[pn]; err != nil {
+			f.mu.Unlock()
+			return nil, err
+		}
+		r := types.IsOnWhatsAppResponse{Query: phone, IsIn: f.registerAll || f.registered[pn]}
+		if r.IsIn {
+			r.JID = types.NewJID(pn, types.DefaultUserServer)
+			if account, ok := f.aliases[pn]; ok {
+				r.JID = types.NewJID(account, types.DefaultUserServer)
+			}
+			if name, ok := f.verified[pn]; ok {
+				r.VerifiedName = &types.VerifiedName{Details: &waVnameCert.VerifiedNameCertificate_Details{VerifiedName: &name}}
+			}
+			if cert, ok := f.certs[pn]; ok {
+				r.VerifiedName = cert
+			}
+		}
+		resp = append(resp, r)
+	}
+	f.mu.Unlock()
+	return resp, nil
+}
+
+func (f *fakeClient) GetBusinessProfile(ctx context.Context, jid types.JID) (*types.BusinessProfile, error) {
+	time.Sleep(f.enrichDelay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enrichCalls++
+	if biz, ok := f.business[jid.User]; ok {
+		return biz, nil
+	}
+	return nil, whatsmeow.ErrIQNotFound
+}
+
+func (f *fakeClient) GetUserInfo(ctx context.Context, jids []types.JID) (map[types.JID]types.UserInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enrichCalls++
+	info := make(map[types.JID]types.UserInfo)
+	for _, jid := range jids {
+		info[jid] = types.UserInfo{Status: f.status[jid.User], Devices: f.devices[jid.User]}
+	}
+	return info, nil
+}
+
+func (f *fakeClient) GetProfilePictureInfo(ctx context.Context, jid types.JID, params *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enrichCalls++
+	if params != nil && params.Preview {
+		if pic, ok := f.previews[jid.User]; ok {
+			return pic, nil
+		}
+	} else if err := f.fullErrs[jid.User]; err != nil {
+		return nil, err
+	}
+	if pic, ok := f.pictures[jid.User]; ok {
+		return pic, nil
+	}
+	return nil, whatsmeow.ErrProfilePictureNotSet
+}
+
+func (f *fakeClient) GetContact(ctx context.Context, jid types.JID) (types.ContactInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enrichCalls++
+	return f.contacts[jid.User], nil
+}
+
+func (f *fakeClient) GetLIDForPN(ctx context.Context, pn types.JID) (types.JID, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lid, ok := f.lids[pn.User]; ok {
+		return types.NewJID(lid, types.HiddenUserServer), nil
+	}
+	return types.JID{}, nil
+}
+
+func (f *fakeClient) enrichCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.enrichCalls
+}
+
+func (f *fakeClient) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
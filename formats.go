@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportFormats maps each -formats name to the flag it fills in and the
+// file extension used for it.
+var exportFormats = []struct {
+	name string
+	ext  string
+	flag *string
+}{
+	{"txt", ".txt", outputFile},
+	{"csv", ".csv", csvFile},
+	{"json", ".json", jsonArray},
+	{"ndjson", ".ndjson", jsonFile},
+	{"vcf", ".vcf", vcardFile},
+	{"xlsx", ".xlsx", xlsxFile},
+	{"sqlite", ".db", sqliteOut},
+}
+
+// applyFormats sets the export flags for every format in list to a file in
+// dir named after the patterns and start time. Formats whose own flag was
+// given keep that path.
+func applyFormats(list, dir string, patterns []string, now time.Time) error {
+	base := filepath.Join(dir, exportBaseName(patterns, now))
+	var selected []int
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "vcard" {
+			name = "vcf"
+		}
+		i := -1
+		for j, f := range exportFormats {
+			if f.name == name {
+				i = j
+			}
+		}
+		if i < 0 {
+			var names []string
+			for _, f := range exportFormats {
+				names = append(names, f.name)
+			}
+			return fmt.Errorf("unknown format %q (use %s)", name, strings.Join(names, ", "))
+		}
+		selected = append(selected, i)
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, i := range selected {
+		if f := exportFormats[i]; *f.flag == "" {
+			*f.flag = base + f.ext
+		}
+	}
+	return nil
+}
+
+// exportBaseName builds a file name like 1555123xxxx_20240102-150405 from
+// the first pattern, with anything but digits and x replaced.
+func exportBaseName(patterns []string, now time.Time) string {
+	name := "contacts"
+	if len(patterns) > 0 {
+		name = strings.Map(func(r rune) rune {
+			if (r >= '0' && r <= '9') || r == 'x' {
+				return r
+			}
+			return '_'
+		}, patterns[0])
+		if len(name) > 40 {
+			name = name[:40]
+		}
+		if len(patterns) > 1 {
+			name += fmt.Sprintf("+%d", len(patterns)-1)
+		}
+	}
+	return name + "_" + now.Format("20060102-150405")
+}
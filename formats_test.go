@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestApplyFormats(t *testing.T) {
+	for _, f := range exportFormats {
+		setFlag(t, f.flag, "")
+	}
+	dir := filepath.Join(t.TempDir(), "results")
+	own := filepath.Join(t.TempDir(), "mine.csv")
+	setFlag(t, csvFile, own)
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	if err := applyFormats("csv, JSON,vcard,txt", dir, []string{"1555123xxxx", "1666xx"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if *csvFile != own {
+		t.Errorf("-csv was given, but -formats changed it to %s", *csvFile)
+	}
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := ScanResult{Phone: "15551230001", JID: "15551230001@c.us", Found: true}
+	w.Write(res)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	base := "1555123xxxx+1_20240102-150405"
+	want := []string{base + ".json", base + ".txt", base + ".vcf"}
+	if !equalStrings(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+	if _, err := os.Stat(own); err != nil {
+		t.Errorf("-csv file: %v", err)
+	}
+}
+
+func TestApplyFormatsUnknown(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "results")
+	if err := applyFormats("csv,pdf", dir, nil, time.Now()); err == nil {
+		t.Error("expected an error for pdf")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("the directory was created despite the error")
+	}
+}
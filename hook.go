@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	hookQueueSize = 256
+	hookWorkers   = 4
+	hookTimeout   = 30 * time.Second
+)
+
+// Hook runs the -on-found command for each result on a small pool of
+// goroutines, so slow commands never hold up the scan. The result is passed
+// as JSON on stdin and the main fields as WABF_* environment variables.
+type Hook struct {
+	command string
+	timeout time.Duration
+	queue   chan ScanResult
+	wg      sync.WaitGroup
+	dropped int64
+}
+
+func NewHook(command string, workers, queueSize int) *Hook {
+	h := &Hook{
+		command: command,
+		timeout: hookTimeout,
+		queue:   make(chan ScanResult, max(queueSize, 1)),
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		h.wg.Add(1)
+		go h.run()
+	}
+	return h
+}
+
+// Send queues a result for the command. If the queue is full the result is
+// dropped rather than blocking the caller; drops are counted, and the first
+// one is always reported.
+func (h *Hook) Send(res ScanResult) {
+	select {
+	case h.queue <- res:
+	default:
+		metrics.Dropped.WithLabelValues("on-found").Inc()
+		if atomic.AddInt64(&h.dropped, 1) == 1 {
+			printWarning("-on-found queue full (%d results), dropping results until it catches up; raise -on-found-queue to keep them\n", cap(h.queue))
+		}
+		if *verbose {
+			slog.Warn("Hook queue full, dropping result", "event", "hook", "phone", res.Phone)
+		}
+	}
+}
+
+// Dropped returns how many results didn't fit in the queue.
+func (h *Hook) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+func (h *Hook) run() {
+	defer h.wg.Done()
+	for res := range h.queue {
+		if err := h.exec(res); err != nil && *verbose {
+			slog.Warn("Hook command failed", "event", "hook", "phone", res.Phone, "error", err)
+		}
+	}
+}
+
+func (h *Hook) exec(res ScanResult) error {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.command)
+	}
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), hookEnv(res)...)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", h.timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+func hookEnv(res ScanResult) []string {
+	env := []string{
+		"WABF_PHONE=" + res.Phone,
+		"WABF_JID=" + res.JID,
+		"WABF_LINK=" + res.Link,
+		"WABF_NAME=" + res.Name,
+		"WABF_STATUS=" + res.Status,
+		"WABF_VERIFIED_NAME=" + res.VerifiedName,
+		"WABF_AVATAR_URL=" + res.AvatarURL,
+	}
+	if res.Business != nil {
+		env = append(env, "WABF_BUSINESS=1")
+	}
+	return env
+}
+
+// Close waits for every queued command to finish.
+func (h *Hook) Close() error {
+	close(h.queue)
+	h.wg.Wait()
+	return nil
+}
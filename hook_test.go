@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// hookScript writes a small shell script that saves its stdin and
+// environment under dir, one pair of files per phone number.
+func hookScript(t *testing.T, dir string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook script needs sh")
+	}
+	script := filepath.Join(dir, "hook.sh")
+	body := `#!/bin/sh
+cat > "$HOOK_DIR/$WABF_PHONE.json"
+env | grep '^WABF_' | sort > "$HOOK_DIR/$WABF_PHONE.env"
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOOK_DIR", dir)
+	return script
+}
+
+func TestHookReceivesResult(t *testing.T) {
+	dir := t.TempDir()
+	hook := NewHook(hookScript(t, dir), 2, hookQueueSize)
+	results := []ScanResult{
+		{Phone: "15550000001", JID: "15550000001@s.whatsapp.net", Name: "Alice", Status: "Hey there"},
+		{Phone: "15550000002", JID: "15550000002@s.whatsapp.net", VerifiedName: "Acme", Business: &types.BusinessProfile{Email: "hi@acme.test"}},
+	}
+	for _, res := range results {
+		hook.Send(res)
+	}
+	hook.Close()
+
+	for _, want := range results {
+		data, err := os.ReadFile(filepath.Join(dir, want.Phone+".json"))
+		if err != nil {
+			t.Fatalf("hook didn't run for %s: %v", want.Phone, err)
+		}
+		var got ScanResult
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("stdin for %s isn't JSON: %v\n%s", want.Phone, err, data)
+		}
+		if got.Phone != want.Phone || got.JID != want.JID || got.Name != want.Name || got.VerifiedName != want.VerifiedName {
+			t.Errorf("stdin = %+v, want %+v", got, want)
+		}
+		if (got.Business != nil) != (want.Business != nil) {
+			t.Errorf("%s: business profile on stdin = %v", want.Phone, got.Business)
+		}
+	}
+
+	env, err := os.ReadFile(filepath.Join(dir, "15550000001.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"WABF_PHONE=15550000001", "WABF_NAME=Alice", "WABF_STATUS=Hey there"} {
+		if !strings.Contains(string(env), want+"\n") {
+			t.Errorf("env is missing %q:\n%s", want, env)
+		}
+	}
+	if strings.Contains(string(env), "WABF_BUSINESS") {
+		t.Errorf("WABF_BUSINESS set for a personal account:\n%s", env)
+	}
+	env, err = os.ReadFile(filepath.Join(dir, "15550000002.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"WABF_VERIFIED_NAME=Acme", "WABF_BUSINESS=1"} {
+		if !strings.Contains(string(env), want+"\n") {
+			t.Errorf("env is missing %q:\n%s", want, env)
+		}
+	}
+}
+
+func TestHookFailureIsLogged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook command needs sh")
+	}
+	setFlag(t, verbose, true)
+	buf := captureLog(t, true)
+
+	hook := NewHook("echo boom >&2; exit 3", 1, hookQueueSize)
+	hook.Send(ScanResult{Phone: "15550000001"})
+	hook.Close()
+
+	lines := logLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1: %v", len(lines), lines)
+	}
+	if lines[0]["phone"] != "15550000001" || !strings.Contains(lines[0]["error"].(string), "boom") {
+		t.Errorf("log line = %v", lines[0])
+	}
+}
+
+func TestHookQueueFull(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook command needs sh")
+	}
+	dir := t.TempDir()
+	t.Setenv("HOOK_DIR", dir)
+	before := testutil.ToFloat64(metrics.Dropped.WithLabelValues("on-found"))
+
+	// Each command waits for the go file, so the first one holds the only
+	// worker while the rest queue up.
+	hook := NewHook(`touch "$HOOK_DIR/$WABF_PHONE"; while [ ! -e "$HOOK_DIR/go" ]; do sleep 0.01; done`, 1, 2)
+	_, stderr := captureOutput(t, func() {
+		hook.Send(ScanResult{Phone: "15550000001"})
+		waitFor(t, "the first command", func() bool {
+			_, err := os.Stat(filepath.Join(dir, "15550000001"))
+			return err == nil
+		})
+		for _, pn := range []string{"15550000002", "15550000003", "15550000004", "15550000005"} {
+			hook.Send(ScanResult{Phone: pn})
+		}
+		os.WriteFile(filepath.Join(dir, "go"), nil, 0644)
+		hook.Close()
+	})
+
+	if got := hook.Dropped(); got != 2 {
+		t.Errorf("Dropped = %d, want 2", got)
+	}
+	for _, pn := range []string{"15550000004", "15550000005"} {
+		if _, err := os.Stat(filepath.Join(dir, pn)); err == nil {
+			t.Errorf("%s ran, but the queue was full", pn)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.Dropped.WithLabelValues("on-found")) - before; got != 2 {
+		t.Errorf("wabf_results_dropped_total{sink=on-found} went up by %v, want 2", got)
+	}
+	if n := strings.Count(stderr, "-on-found queue full"); n != 1 || !strings.Contains(stderr, "-on-found-queue") {
+		t.Errorf("stderr = %q, want one warning", stderr)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type LatencyStats struct {
+	mu      sync.Mutex
+	samples []time.Duration
+}
+
+func (l *LatencyStats) Add(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append(l.samples, d)
+}
+
+func (l *LatencyStats) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.samples)
+}
+
+func (l *LatencyStats) Summary() (min, avg, max, p95 time.Duration) {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0, 0, 0, 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return sorted[0], total / time.Duration(len(sorted)), sorted[len(sorted)-1], percentile(sorted, 95)
+}
+
+// percentile uses the nearest-rank method on an ascending slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
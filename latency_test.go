@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencySummary(t *testing.T) {
+	var l LatencyStats
+	for i := 20; i >= 1; i-- {
+		l.Add(time.Duration(i) * time.Millisecond)
+	}
+	min, avg, max, p95 := l.Summary()
+	if min != time.Millisecond || max != 20*time.Millisecond {
+		t.Errorf("min %s max %s", min, max)
+	}
+	if avg != 10500*time.Microsecond {
+		t.Errorf("avg %s, want 10.5ms", avg)
+	}
+	if p95 != 19*time.Millisecond {
+		t.Errorf("p95 %s, want 19ms", p95)
+	}
+}
+
+func TestLatencySummaryEmpty(t *testing.T) {
+	var l LatencyStats
+	if min, avg, max, p95 := l.Summary(); min != 0 || avg != 0 || max != 0 || p95 != 0 {
+		t.Errorf("got %s %s %s %s for no samples", min, avg, max, p95)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 1}, {10, 1}, {50, 5}, {90, 9}, {95, 10}, {100, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("p%.0f = %d, want %d", tt.p, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// setupLogging switches diagnostics to JSON lines on stderr. Without it the
+// default slog handler keeps writing through the standard log package.
+func setupLogging(jsonOutput, verbose bool) {
+	if !jsonOutput {
+		return
+	}
+	slog.SetDefault(slog.New(jsonLogHandler(os.Stderr, verbose)))
+}
+
+// jsonLogHandler logs warnings and errors, or everything with verbose.
+func jsonLogHandler(w io.Writer, verbose bool) slog.Handler {
+	level := slog.LevelWarn
+	if verbose {
+		level = slog.LevelDebug
+	}
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+}
+
+// fail logs a fatal error and returns code for run to exit with.
+func fail(code int, msg string, err error) int {
+	slog.Error(msg, "event", "fatal", "error", err)
+	return code
+}
+
+type slogWALogger struct {
+	l   *slog.Logger
+	mod string
+	min slog.Level
+}
+
+func newSlogWALogger(module string, min slog.Level) waLog.Logger {
+	return &slogWALogger{l: slog.Default(), mod: module, min: min}
+}
+
+func (s *slogWALogger) log(level slog.Level, msg string, args []interface{}) {
+	if level < s.min {
+		return
+	}
+	s.l.Log(context.Background(), level, fmt.Sprintf(msg, args...), "module", s.mod)
+}
+
+func (s *slogWALogger) Errorf(msg string, args ...interface{}) { s.log(slog.LevelError, msg, args) }
+func (s *slogWALogger) Warnf(msg string, args ...interface{})  { s.log(slog.LevelWarn, msg, args) }
+func (s *slogWALogger) Infof(msg string, args ...interface{})  { s.log(slog.LevelInfo, msg, args) }
+func (s *slogWALogger) Debugf(msg string, args ...interface{}) { s.log(slog.LevelDebug, msg, args) }
+
+func (s *slogWALogger) Sub(module string) waLog.Logger {
+	return &slogWALogger{l: s.l, mod: s.mod + "/" + module, min: s.min}
+}
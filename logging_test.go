@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// captureLog sends the default slog logger to a buffer of JSON lines for
+// the duration of the test.
+func captureLog(t *testing.T, verbose bool) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(jsonLogHandler(&buf, verbose)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("%q is not JSON: %v", line, err)
+		}
+		lines = append(lines, obj)
+	}
+	return lines
+}
+
+func TestJSONLogging(t *testing.T) {
+	buf := captureLog(t, false)
+	slog.Info("Checking", "event", "check", "phone", "15550000001")
+	if code := fail(exitError, "Failed to open exports", errors.New("disk full")); code != exitError {
+		t.Errorf("fail returned %d", code)
+	}
+
+	lines := logLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want only the error without -verbose: %s", len(lines), buf)
+	}
+	got := lines[0]
+	if got["level"] != "ERROR" || got["event"] != "fatal" || got["error"] != "disk full" || got["msg"] != "Failed to open exports" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestJSONLoggingVerbose(t *testing.T) {
+	buf := captureLog(t, true)
+	slog.Info("Checking", "event", "check", "phone", "15550000001")
+	newSlogWALogger("Client", slog.LevelInfo).Sub("Socket").Infof("connected to %s", "web.whatsapp.com")
+	newSlogWALogger("Client", slog.LevelInfo).Debugf("dropped below the minimum level")
+
+	lines := logLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %s", len(lines), buf)
+	}
+	if lines[0]["phone"] != "15550000001" || lines[0]["event"] != "check" {
+		t.Errorf("scan line = %v", lines[0])
+	}
+	if lines[1]["module"] != "Client/Socket" || lines[1]["msg"] != "connected to web.whatsapp.com" {
+		t.Errorf("whatsmeow line = %v", lines[1])
+	}
+}
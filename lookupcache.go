@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const lookupCacheSchema = `CREATE TABLE IF NOT EXISTS wabf_lookup_cache (
+	phone       TEXT PRIMARY KEY,
+	on_whatsapp INTEGER NOT NULL,
+	jid         TEXT,
+	checked_at  INTEGER NOT NULL
+)`
+
+// LookupCache remembers IsOnWhatsApp answers (including negative ones) so
+// overlapping scans don't query the same numbers again.
+type LookupCache struct {
+	db   *sql.DB
+	ttl  time.Duration
+	now  func() time.Time
+	hits int64
+}
+
+type CachedLookup struct {
+	OnWhatsApp bool
+	JID        string
+	CheckedAt  time.Time
+}
+
+func OpenLookupCache(path string, ttl time.Duration) (*LookupCache, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(lookupCacheSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &LookupCache{db: db, ttl: ttl, now: time.Now}, nil
+}
+
+// Get returns the cached answer for pn if it is younger than the TTL.
+func (c *LookupCache) Get(pn string) (CachedLookup, bool, error) {
+	var entry CachedLookup
+	var checkedAt int64
+	err := c.db.QueryRow(`SELECT on_whatsapp, jid, checked_at FROM wabf_lookup_cache WHERE phone = ?`,
+		normalizePhone(pn)).Scan(&entry.OnWhatsApp, &entry.JID, &checkedAt)
+	if err == sql.ErrNoRows {
+		return entry, false, nil
+	} else if err != nil {
+		return entry, false, err
+	}
+	entry.CheckedAt = time.Unix(checkedAt, 0)
+	if c.now().Sub(entry.CheckedAt) >= c.ttl {
+		return entry, false, nil
+	}
+	atomic.AddInt64(&c.hits, 1)
+	return entry, true, nil
+}
+
+func (c *LookupCache) Put(pn string, onWhatsApp bool, jid string) error {
+	_, err := c.db.Exec(`INSERT INTO wabf_lookup_cache (phone, on_whatsapp, jid, checked_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (phone) DO UPDATE SET on_whatsapp = excluded.on_whatsapp, jid = excluded.jid, checked_at = excluded.checked_at`,
+		normalizePhone(pn), onWhatsApp, jid, c.now().Unix())
+	return err
+}
+
+// Known returns, as JIDs, every number the cache knows is on WhatsApp plus
+// every number checked within the TTL, for -skip-cached.
+func (c *LookupCache) Known() (map[string]bool, error) {
+	rows, err := c.db.Query(`SELECT phone FROM wabf_lookup_cache WHERE on_whatsapp = 1 OR checked_at > ?`,
+		c.now().Add(-c.ttl).Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	known := make(map[string]bool)
+	for rows.Next() {
+		var phone string
+		if err := rows.Scan(&phone); err != nil {
+			return nil, err
+		}
+		known[phone+"@c.us"] = true
+	}
+	return known, rows.Err()
+}
+
+func (c *LookupCache) Hits() int64 {
+	return atomic.LoadInt64(&c.hits)
+}
+
+func (c *LookupCache) Close() error {
+	return c.db.Close()
+}
+
+func normalizePhone(pn string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, pn)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestCache(t *testing.T, ttl time.Duration) *LookupCache {
+	t.Helper()
+	cache, err := OpenLookupCache(filepath.Join(t.TempDir(), "wabf.db"), ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestLookupCache(t *testing.T) {
+	cache := openTestCache(t, time.Hour)
+	now := time.Unix(1_700_000_000, 0)
+	cache.now = func() time.Time { return now }
+
+	if _, ok, err := cache.Get("15550000001"); ok || err != nil {
+		t.Fatalf("empty cache: hit %v, err %v", ok, err)
+	}
+	cache.Put("+1 555 000 0001", true, "15550000001@s.whatsapp.net")
+	cache.Put("15550000002", false, "")
+
+	entry, ok, err := cache.Get("15550000001")
+	if !ok || err != nil || !entry.OnWhatsApp || entry.JID != "15550000001@s.whatsapp.net" {
+		t.Errorf("hit: %+v, %v, %v", entry, ok, err)
+	}
+	// Negative answers are cached too.
+	if entry, ok, _ := cache.Get("15550000002"); !ok || entry.OnWhatsApp {
+		t.Errorf("negative answer: %+v, %v", entry, ok)
+	}
+	if cache.Hits() != 2 {
+		t.Errorf("Hits = %d, want 2", cache.Hits())
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok, _ := cache.Get("15550000001"); ok {
+		t.Error("entry should have expired after the TTL")
+	}
+}
+
+func TestScannerUsesLookupCache(t *testing.T) {
+	cache := openTestCache(t, time.Hour)
+	client := newFakeClient("15550000003")
+	s := &Scanner{Client: client, Cache: cache}
+	first := scanPhones(t, s, "1555000000x")
+	calls := client.callCount()
+
+	s = &Scanner{Client: client, Cache: cache}
+	second := scanPhones(t, s, "1555000000x")
+	if client.callCount() != calls {
+		t.Errorf("second scan made %d more requests, want all from the cache", client.callCount()-calls)
+	}
+	if !equalStrings(first, second) || !equalStrings(second, []string{"15550000003"}) {
+		t.Errorf("first %v, second %v", first, second)
+	}
+}
+
+func TestSkipCached(t *testing.T) {
+	cache := openTestCache(t, time.Hour)
+	now := time.Unix(1_700_000_000, 0)
+	cache.now = func() time.Time { return now.Add(-2 * time.Hour) }
+	cache.Put("15550000001", true, "15550000001@s.whatsapp.net")
+	cache.Put("15550000002", false, "")
+	cache.now = func() time.Time { return now }
+	cache.Put("15550000003", false, "")
+
+	known, err := cache.Known()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A positive answer is known however old it is; a negative one only
+	// until it expires.
+	if !known["15550000001@c.us"] || known["15550000002@c.us"] || !known["15550000003@c.us"] || len(known) != 2 {
+		t.Fatalf("Known = %v", known)
+	}
+
+	client := newFakeClient("15550000001", "15550000002", "15550000004")
+	s := &Scanner{Client: client, Skip: known}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000002", "15550000004"}) {
+		t.Errorf("found %v", got)
+	}
+	for _, req := range client.requests {
+		for _, phone := range req {
+			if pn := normalizePhone(phone); pn == "15550000001" || pn == "15550000003" {
+				t.Errorf("cached number %s reached a worker", pn)
+			}
+		}
+	}
+	if got := client.callCount(); got != 8 {
+		t.Errorf("checked %d numbers, want the 8 not in the cache", got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus collectors for a scan and the HTTP server
+// that exposes them on /metrics.
+type Metrics struct {
+	Checked prometheus.Counter
+	Found   prometheus.Counter
+	Errors  prometheus.Counter
+	Latency prometheus.Histogram
+	// Dropped counts results a full webhook or -on-found queue dropped,
+	// labelled by sink.
+	Dropped *prometheus.CounterVec
+
+	server *http.Server
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Checked: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "wabf_checked_total",
+			Help: "Numbers checked against WhatsApp.",
+		}),
+		Found: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "wabf_found_total",
+			Help: "Accounts found and reported.",
+		}),
+		Errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "wabf_check_errors_total",
+			Help: "IsOnWhatsApp requests that failed after retries.",
+		}),
+		Latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "wabf_check_duration_seconds",
+			Help:    "Latency of IsOnWhatsApp requests.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 10),
+		}),
+		Dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "wabf_results_dropped_total",
+			Help: "Found results dropped because a webhook or -on-found queue was full.",
+		}, []string{"sink"}),
+	}
+}
+
+func (m *Metrics) Handler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(m.Checked, m.Found, m.Errors, m.Latency, m.Dropped)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Serve starts the metrics server on addr. The listener is opened before
+// returning so a bad address fails immediately.
+func (m *Metrics) Serve(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	m.server = &http.Server{Handler: mux}
+	go func() {
+		if err := m.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) && *verbose {
+			slog.Warn("Metrics server stopped", "event", "metrics", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (m *Metrics) Close() error {
+	if m.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.server.Shutdown(ctx)
+}
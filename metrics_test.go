@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	m := NewMetrics()
+	ts := httptest.NewServer(m.Handler())
+	defer ts.Close()
+
+	client := newFakeClient("15550000001")
+	client.numberErrs["15550000002"] = errors.New("bad request")
+	s := &Scanner{Client: client, Metrics: m}
+	scanPhones(t, s, "1555000000[1-3]")
+	m.Checked.Add(3)
+	m.Found.Inc()
+
+	body := scrape(t, ts.URL)
+	for _, want := range []string{
+		"wabf_checked_total 3",
+		"wabf_found_total 1",
+		"wabf_check_errors_total 1",
+		"wabf_check_duration_seconds_count 3",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics are missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsServe(t *testing.T) {
+	m := NewMetrics()
+	if err := m.Serve("127.0.0.1:-1"); err == nil {
+		t.Error("bad address should fail at once")
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("Close without a server: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseRange turns a -range value such as "15551230000..15551239999" into
+// patterns that together match exactly the numbers in the inclusive range,
+// e.g. "1555123xxxx", so the rest of the pipeline only ever sees patterns.
+func parseRange(spec string) ([]string, error) {
+	lo, hi, ok := strings.Cut(spec, "..")
+	if !ok {
+		return nil, fmt.Errorf("invalid -range %q: want start..end, e.g. 15551230000..15551239999", spec)
+	}
+	lo, hi = normalizePattern(lo), normalizePattern(hi)
+	if !isDigits(lo) || !isDigits(hi) {
+		return nil, fmt.Errorf("invalid -range %q: start and end must be plain numbers", spec)
+	}
+	if len(lo) != len(hi) {
+		return nil, fmt.Errorf("invalid -range %q: start and end must have the same number of digits", spec)
+	}
+	// Same length, so comparing the strings compares the numbers.
+	if lo > hi {
+		return nil, fmt.Errorf("invalid -range %q: start is greater than end", spec)
+	}
+	return rangePatterns(lo, hi), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// rangePatterns splits lo..hi (equal length, lo <= hi) into as few patterns
+// as possible: a partial block at each end and whole blocks in between.
+func rangePatterns(lo, hi string) []string {
+	if lo == hi {
+		return []string{lo}
+	}
+	i := 0
+	for lo[i] == hi[i] {
+		i++
+	}
+	prefix, a, b := lo[:i], lo[i], hi[i]
+	rest := len(lo) - i - 1
+	zeros, nines := strings.Repeat("0", rest), strings.Repeat("9", rest)
+
+	var patterns []string
+	first, last := a, b
+	if lo[i+1:] != zeros {
+		patterns = append(patterns, rangePatterns(lo, prefix+string(a)+nines)...)
+		first++
+	}
+	if hi[i+1:] != nines {
+		last--
+	}
+	if first <= last {
+		patterns = append(patterns, prefix+digitClass(first, last)+strings.Repeat("x", rest))
+	}
+	if hi[i+1:] != nines {
+		patterns = append(patterns, rangePatterns(prefix+string(b)+zeros, hi)...)
+	}
+	return patterns
+}
+
+func digitClass(first, last byte) string {
+	switch {
+	case first == last:
+		return string(first)
+	case first == '0' && last == '9':
+		return "x"
+	}
+	return fmt.Sprintf("[%c-%c]", first, last)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"15551230000..15551239999", []string{"1555123xxxx"}},
+		{"15551230000..15551230000", []string{"15551230000"}},
+		{"+1 555 123 0000..+1 555 123 0009", []string{"1555123000x"}},
+		{"15551230000..15551234999", []string{"1555123[0-4]xxx"}},
+		{"15551230005..15551230012", []string{"1555123000[5-9]", "1555123001[0-2]"}},
+		{"15551230095..15551230204", []string{"1555123009[5-9]", "155512301xx", "1555123020[0-4]"}},
+	}
+	for _, tt := range tests {
+		got, err := parseRange(tt.spec)
+		if err != nil {
+			t.Errorf("parseRange(%q): %v", tt.spec, err)
+			continue
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("parseRange(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// TestParseRangeCoversRange walks the patterns for random ranges and checks
+// they produce every number from start to end exactly once, in order.
+func TestParseRangeCoversRange(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		lo := 1_000_000 + r.Intn(9_000_000)
+		hi := lo + r.Intn(3000)
+		if hi > 9_999_999 {
+			hi = 9_999_999
+		}
+		spec := strconv.Itoa(lo) + ".." + strconv.Itoa(hi)
+		patterns, err := parseRange(spec)
+		if err != nil {
+			t.Fatalf("parseRange(%q): %v", spec, err)
+		}
+		next := lo
+		for _, p := range patterns {
+			for _, jid := range walkAll(t, p) {
+				if want := strconv.Itoa(next) + "@c.us"; jid != want {
+					t.Fatalf("%s (%v): got %s, want %s", spec, patterns, jid, want)
+				}
+				next++
+			}
+		}
+		if next != hi+1 {
+			t.Fatalf("%s (%v): stopped at %d", spec, patterns, next-1)
+		}
+	}
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for spec, want := range map[string]string{
+		"15551239999..15551230000": "start is greater than end",
+		"15551230000..1555123999":  "same number of digits",
+		"1555123000..15551239999":  "same number of digits",
+		"15551230000-15551239999":  "want start..end",
+		"1555123xxxx..15551239999": "plain numbers",
+		"..15551239999":            "plain numbers",
+	} {
+		if _, err := parseRange(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseRange(%q): err %v, want %q", spec, err, want)
+		}
+	}
+}
+
+func TestRangeFlag(t *testing.T) {
+	got := dryRunNumbers(t, "-range", "15550000008..15550000012")
+	want := []string{"15550000008", "15550000009", "15550000010", "15550000011", "15550000012"}
+	if !equalStrings(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if code := runArgs(t, "-dry-run", "-range", "15550000012..15550000008"); code != exitUsage {
+		t.Errorf("reversed -range: exit code %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipFile closes the gzip stream before the file underneath it.
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Writer.Close()
+	if closeErr := g.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// createOutput creates an export file, gzip-compressing it when the name
+// ends in .gz.
+func createOutput(path string) (io.WriteCloser, error) {
+	w, _, err := openOutput(path, false)
+	return w, err
+}
+
+// openOutput is createOutput that, with appendTo, keeps what is already in
+// the file and writes after it. empty reports whether the file had no
+// content, so callers know whether to write a header. Appending to a .gz
+// file adds a new gzip member, which gzip readers treat as one stream.
+func openOutput(path string, appendTo bool) (w io.WriteCloser, empty bool, err error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		return nil, false, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	empty = info.Size() == 0
+	if !strings.HasSuffix(strings.ToLower(path), ".gz") {
+		return f, empty, nil
+	}
+	return &gzipFile{Writer: gzip.NewWriter(f), f: f}, empty, nil
+}
+
+// JSONArrayWriter writes -json-file: the opening bracket straight away, then
+// each result as it comes, so a crash leaves every flushed result on disk
+// with only the closing bracket missing. With sortBy set, results are held
+// back and written in sortResults order when the writer is closed.
+type JSONArrayWriter struct {
+	w       io.WriteCloser
+	n       int
+	sortBy  string
+	pending []ScanResult
+}
+
+func NewJSONArrayWriter(path, sortBy string) (*JSONArrayWriter, error) {
+	w, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, "["); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return &JSONArrayWriter{w: w, sortBy: sortBy}, nil
+}
+
+func (j *JSONArrayWriter) Write(res ScanResult) error {
+	if j.sortBy != "" {
+		j.pending = append(j.pending, res)
+		return nil
+	}
+	return j.writeItem(res)
+}
+
+// writeItem appends res indented the way json.MarshalIndent lays out the
+// whole array.
+func (j *JSONArrayWriter) writeItem(res ScanResult) error {
+	data, err := json.MarshalIndent(res, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if j.n == 0 {
+		sep = "\n  "
+	}
+	if _, err := io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	j.n++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *JSONArrayWriter) Flush() error {
+	if f, ok := j.w.(flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (j *JSONArrayWriter) Close() error {
+	var err error
+	sortResults(j.pending, j.sortBy)
+	for _, res := range j.pending {
+		if err = j.writeItem(res); err != nil {
+			break
+		}
+	}
+	end := "]\n"
+	if j.n > 0 {
+		end = "\n]\n"
+	}
+	if err == nil {
+		_, err = io.WriteString(j.w, end)
+	}
+	if closeErr := j.w.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
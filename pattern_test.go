@@ -0,0 +1,403 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cartesianJIDs is the old, materialising expansion: every combination of
+// the fills built up front, kept as the reference for walkPattern's order.
+func cartesianJIDs(t testing.TB, pattern string) []string {
+	if !hasWildcards(pattern) {
+		return []string{pattern + "@c.us"}
+	}
+	parts, fills, err := parsePattern(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	combos := [][]string{{}}
+	for _, fill := range fills {
+		var next [][]string
+		for _, c := range combos {
+			for _, f := range fill {
+				next = append(next, append(append([]string(nil), c...), f))
+			}
+		}
+		combos = next
+	}
+	jids := make([]string, 0, len(combos))
+	for _, c := range combos {
+		var sb strings.Builder
+		for i, f := range c {
+			sb.WriteString(parts[i])
+			sb.WriteString(f)
+		}
+		sb.WriteString(parts[len(c)])
+		sb.WriteString("@c.us")
+		jids = append(jids, sb.String())
+	}
+	return jids
+}
+
+func walkAll(t testing.TB, pattern string) []string {
+	var jids []string
+	if err := walkPattern(pattern, func(jid string) bool {
+		jids = append(jids, jid)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return jids
+}
+
+func TestWalkPatternMatchesCartesian(t *testing.T) {
+	for _, pattern := range []string{
+		"15551234567",
+		"1555123456x",
+		"155512345xx",
+		"1555[1-3]2x4[57]",
+		"1555x{3}",
+		"1(555|666)12x",
+		"1555[0-2](7|8)x",
+	} {
+		got, want := walkAll(t, pattern), cartesianJIDs(t, pattern)
+		if !equalStrings(got, want) {
+			t.Errorf("%s: streamed %d JIDs, reference %d; first %v vs %v", pattern, len(got), len(want), head(got), head(want))
+		}
+	}
+}
+
+func TestWalkPatternStops(t *testing.T) {
+	n := 0
+	walkPattern("1555xxxxxxx", func(string) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Errorf("walked %d JIDs after asking to stop at 5", n)
+	}
+}
+
+func head(s []string) []string {
+	return s[:min(len(s), 3)]
+}
+
+// Compare with -benchmem: streaming allocates per JID only, while the
+// materialised expansion grows with the size of the range.
+func BenchmarkWalkPattern(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		walkPattern("1555xxxxx", func(string) bool { return true })
+	}
+}
+
+func BenchmarkCartesianJIDs(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		cartesianJIDs(b, "1555xxxxx")
+	}
+}
+
+func TestExpandBracket(t *testing.T) {
+	tests := []struct {
+		options string
+		want    string
+		wantErr bool
+	}{
+		{"5", "5", false},
+		{"123", "123", false},
+		{"3-7", "34567", false},
+		{"4-4", "4", false},
+		{"0-24", "0124", false},
+		{"13-58", "13458", false},
+		{"1-31-3", "123", false},
+		{"0-9", "0123456789", false},
+		{"7-3", "", true},
+		{"3-", "", true},
+		{"a", "", true},
+		{"1-a", "", true},
+	}
+	for _, tt := range tests {
+		got, err := expandBracket(tt.options)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("[%s]: err = %v, wantErr %v", tt.options, err, tt.wantErr)
+			continue
+		}
+		if err == nil && strings.Join(got, "") != tt.want {
+			t.Errorf("[%s] = %v, want %s", tt.options, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyGroups(t *testing.T) {
+	for _, pattern := range []string{"[]", "1[]2", "1555[]", "1[]x[]2", "1[[]]2", "1[[1]]2", "(1|[])5", "1()2"} {
+		if _, err := patternSize(pattern); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		}
+		if err := walkPattern(pattern, func(string) bool { return true }); err == nil {
+			t.Errorf("%s: walkPattern should fail rather than yield nothing", pattern)
+		}
+	}
+	// -force skips the size check, but not the pattern error.
+	if code := runArgs(t, "-dry-run", "-force", "1[]2"); code != exitUsage {
+		t.Errorf("exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestWalkPatternRanges(t *testing.T) {
+	got := walkAll(t, "155512345[0-24]")
+	want := []string{"1555123450@c.us", "1555123451@c.us", "1555123452@c.us", "1555123454@c.us"}
+	if !equalStrings(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := patternSize("1555[9-0]"); err == nil {
+		t.Error("reversed range should be rejected")
+	}
+}
+
+func TestQuantifier(t *testing.T) {
+	pairs := [][2]string{
+		{"1555x{4}", "1555xxxx"},
+		{"1555x{1}", "1555x"},
+		{"1x{2}5[12]x{2}", "1xx5[12]xx"},
+	}
+	for _, p := range pairs {
+		if got, want := walkAll(t, p[0]), walkAll(t, p[1]); !equalStrings(got, want) {
+			t.Errorf("%s and %s differ: %d vs %d JIDs", p[0], p[1], len(got), len(want))
+		}
+	}
+	for _, bad := range []string{"1555x{0}", "1555x{}", "1555x{a}", "1555x{-2}", "1555x{3", "1555x}", "1555{3}", "15x{2}5x{"} {
+		if _, _, err := parsePattern(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestAlternation(t *testing.T) {
+	got := walkAll(t, "(1|44)555x")
+	if len(got) != 20 || got[0] != "15550@c.us" || got[10] != "445550@c.us" {
+		t.Errorf("(1|44)555x: got %v", got)
+	}
+	if got := walkAll(t, "(44|49|44)1x"); len(got) != 20 {
+		t.Errorf("duplicate alternative should be checked once: got %d JIDs", len(got))
+	}
+	got = walkAll(t, "1(55|66)x[12]")
+	if len(got) != 40 || got[0] != "15501@c.us" || got[1] != "15502@c.us" || got[39] != "16692@c.us" {
+		t.Errorf("1(55|66)x[12]: got %d JIDs: %v", len(got), got)
+	}
+	if got, want := walkAll(t, "(7)x"), walkAll(t, "7x"); !equalStrings(got, want) {
+		t.Errorf("(7)x and 7x differ: %v vs %v", got, want)
+	}
+	for _, bad := range []string{"(1|44", "1|44)5", "(1||44)5", "(|1)5", "()5", "(1|4a)5", "((1|2)|3)5"} {
+		if _, _, err := parsePattern(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestReadPatternFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.txt")
+	content := "# overlapping blocks\n\n1555000000x\n   \n155500000[05-9]x\n# 1666xxxx\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	patterns, _, err := readPatternFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(patterns, []string{"1555000000x", "155500000[05-9]x"}) {
+		t.Errorf("got %v", patterns)
+	}
+
+	// The first block is contained in the second, so it adds nothing.
+	var jids []string
+	streamJIDs(append(patterns, "15550000001"), func(jid string) bool {
+		jids = append(jids, jid)
+		return true
+	})
+	if len(jids) != 60 {
+		t.Errorf("generated %d JIDs, want 60 distinct ones", len(jids))
+	}
+
+	if _, _, err := readPatternFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("missing file should be an error")
+	}
+}
+
+func TestReadPatternFileOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocks.txt")
+	content := "1555000000x delay=500ms concurrency=2\n" +
+		"+1 555 000 001x jitter=0s  # no jitter for this block\n" +
+		"1555000002x\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	patterns, opts, err := readPatternFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(patterns, []string{"1555000000x", "1555000001x", "1555000002x"}) {
+		t.Fatalf("got %v", patterns)
+	}
+	delay, jitter := 500*time.Millisecond, time.Duration(0)
+	want := []PatternOptions{
+		{Delay: &delay, Concurrency: 2},
+		{Jitter: &jitter},
+		{},
+	}
+	for i := range want {
+		if !opts[i].equal(want[i]) {
+			t.Errorf("line %d: options %+v, want %+v", i+1, opts[i], want[i])
+		}
+	}
+
+	base := pacing{delay: 200 * time.Millisecond, jitter: 100 * time.Millisecond}
+	if got := opts[0].pacing(base); got != (pacing{500 * time.Millisecond, 100 * time.Millisecond}) {
+		t.Errorf("pacing with delay=500ms: %+v", got)
+	}
+	if got := opts[1].pacing(base); got != (pacing{200 * time.Millisecond, 0}) {
+		t.Errorf("pacing with jitter=0s: %+v", got)
+	}
+
+	for content, wantErr := range map[string]string{
+		"1555000000x\n1555000001x speed=fast\n": ":2: unknown option \"speed\"",
+		"1555000000x delay=soon\n":              ":1: invalid delay \"soon\"",
+		"1555000000x jitter=-1s\n":              ":1: invalid jitter \"-1s\"",
+		"\n1555000000x concurrency=0\n":         ":2: invalid concurrency \"0\"",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readPatternFile(path); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: err %v, want %q", content, err, wantErr)
+		}
+	}
+}
+
+func TestCheckPatternSize(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		limit    int
+		wantErr  bool
+	}{
+		{[]string{"1555xxx"}, 1000, false},
+		{[]string{"1555xxxx"}, 1000, true},
+		{[]string{"1555xx", "1666xx"}, 200, false},
+		{[]string{"1555xx", "1666xx", "1777x"}, 200, true},
+		{[]string{"1x{40}"}, 1000000, true},
+		{[]string{"15551234567"}, 1, false},
+	}
+	for _, tt := range tests {
+		if err := checkPatternSize(tt.patterns, tt.limit); (err != nil) != tt.wantErr {
+			t.Errorf("%v with limit %d: err = %v, wantErr %v", tt.patterns, tt.limit, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMaxNumbersFlag(t *testing.T) {
+	if code := runArgs(t, "-dry-run", "-max-numbers", "100", "1555xxx"); code != exitUsage {
+		t.Errorf("over the limit: exit code %d, want %d", code, exitUsage)
+	}
+	if code := runArgs(t, "-dry-run", "-max-numbers", "100", "-force", "-output-file", filepath.Join(t.TempDir(), "out.txt"), "1555xxx"); code != exitOK {
+		t.Errorf("with -force: exit code %d, want %d", code, exitOK)
+	}
+}
+
+func TestApplyAffixes(t *testing.T) {
+	tests := []struct {
+		prefix, suffix, pattern, want string
+	}{
+		{"", "", "1555x", "1555x"},
+		{"+1 555", "", "123[0-9]", "1555123[0-9]"},
+		{"", "00", "1555x", "1555x00"},
+		{"1555", "9", "12x", "155512x9"},
+	}
+	for _, tt := range tests {
+		setFlag(t, prefix, tt.prefix)
+		setFlag(t, suffix, tt.suffix)
+		if got := applyAffixes(tt.pattern); got != tt.want {
+			t.Errorf("prefix %q, suffix %q, %s: got %s, want %s", tt.prefix, tt.suffix, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestAffixesApplyToFilePatterns(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "blocks.txt")
+	out := filepath.Join(dir, "out.txt")
+	os.WriteFile(input, []byte("12x\n"), 0644)
+	if code := runArgs(t, "-dry-run", "-prefix", "1555", "-suffix", "9", "-input-file", input, "-output-file", out, "-output-format", "pn", "34"); code != exitOK {
+		t.Fatalf("exit code %d", code)
+	}
+	data, _ := os.ReadFile(out)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 11 || lines[0] != "1555349" || lines[1] != "15551209" {
+		t.Errorf("got %q", data)
+	}
+}
+
+func shuffled(t *testing.T, seed int64, patterns ...string) []string {
+	var jids []string
+	if err := forEachJID(patterns, true, seed, func(jid string) bool {
+		jids = append(jids, jid)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return jids
+}
+
+func TestShuffle(t *testing.T) {
+	ordered := walkAll(t, "1555xxx")
+	got := shuffled(t, 42, "1555xxx")
+	if equalStrings(got, ordered) {
+		t.Error("shuffled order is still ascending")
+	}
+	sorted := append([]string(nil), got...)
+	sort.Strings(sorted)
+	if !equalStrings(sorted, ordered) {
+		t.Errorf("shuffled set differs from the original: %d numbers, want %d", len(got), len(ordered))
+	}
+
+	if again := shuffled(t, 42, "1555xxx"); !equalStrings(again, got) {
+		t.Error("the same seed gave a different order")
+	}
+	if other := shuffled(t, 7, "1555xxx"); equalStrings(other, got) {
+		t.Error("a different seed gave the same order")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		pn    string
+		valid bool
+	}{
+		{"16502530000", true},
+		{"442071838750", true},
+		{"4915123456789", true},
+		{"10502530000", false},
+		{"1650253000", false},
+		{"44207183875099", false},
+		{"999123456", false},
+	}
+	for _, tt := range tests {
+		if got := isValidNumber(tt.pn); got != tt.valid {
+			t.Errorf("isValidNumber(%s) = %v, want %v", tt.pn, got, tt.valid)
+		}
+	}
+
+	var kept []string
+	filtered := 0
+	fn := withValidation(func(jid string) bool {
+		kept = append(kept, jid)
+		return true
+	}, &filtered, true, false)
+	for _, tt := range tests {
+		fn(tt.pn + "@c.us")
+	}
+	if !equalStrings(kept, []string{"16502530000@c.us", "442071838750@c.us", "4915123456789@c.us"}) || filtered != 4 {
+		t.Errorf("kept %v, filtered %d", kept, filtered)
+	}
+}
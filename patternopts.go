@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PatternOptions are the overrides a pattern file line can carry after the
+// pattern, e.g. "1555xxx delay=500ms concurrency=2". Unset fields fall back
+// to the global flags.
+type PatternOptions struct {
+	Delay       *time.Duration
+	Jitter      *time.Duration
+	Concurrency int
+}
+
+func (o PatternOptions) IsZero() bool {
+	return o.Delay == nil && o.Jitter == nil && o.Concurrency == 0
+}
+
+func (o PatternOptions) equal(p PatternOptions) bool {
+	return durationEq(o.Delay, p.Delay) && durationEq(o.Jitter, p.Jitter) && o.Concurrency == p.Concurrency
+}
+
+func durationEq(a, b *time.Duration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// pacing is the delay and jitter applied before each IsOnWhatsApp call.
+type pacing struct {
+	delay  time.Duration
+	jitter time.Duration
+}
+
+// pacing returns base with the options' overrides applied.
+func (o PatternOptions) pacing(base pacing) pacing {
+	p := base
+	if o.Delay != nil {
+		p.delay = *o.Delay
+	}
+	if o.Jitter != nil {
+		p.jitter = *o.Jitter
+	}
+	return p
+}
+
+// splitPatternLine separates a pattern file line into the pattern and its
+// key=value options.
+func splitPatternLine(line string) (string, PatternOptions, error) {
+	var opts PatternOptions
+	var pattern []string
+	for _, field := range strings.Fields(line) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			pattern = append(pattern, field)
+			continue
+		}
+		if err := opts.set(key, value); err != nil {
+			return "", opts, err
+		}
+	}
+	return strings.Join(pattern, " "), opts, nil
+}
+
+// set parses one option, as written in a pattern file line or a -serve
+// request.
+func (o *PatternOptions) set(key, value string) error {
+	switch key {
+	case "delay", "jitter":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid %s %q", key, value)
+		}
+		if key == "delay" {
+			o.Delay = &d
+		} else {
+			o.Jitter = &d
+		}
+	case "concurrency":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid concurrency %q", value)
+		}
+		o.Concurrency = n
+	default:
+		return fmt.Errorf("unknown option %q (want delay, jitter or concurrency)", key)
+	}
+	return nil
+}
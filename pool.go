@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+var errNoSessions = errors.New("no logged-in sessions left")
+
+// ClientPool spreads requests over several logged-in accounts (-sessions),
+// handing each call to the next connected client in turn. A client that is
+// logged out is removed from the rotation.
+type ClientPool struct {
+	mu      sync.Mutex
+	members []*poolMember
+	next    int
+}
+
+type poolMember struct {
+	name      string
+	client    WAClient
+	connected bool
+}
+
+func NewClientPool() *ClientPool {
+	return &ClientPool{}
+}
+
+func (p *ClientPool) Add(name string, client WAClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.members = append(p.members, &poolMember{name: name, client: client, connected: true})
+}
+
+// SetConnected records a session's connection state and reports how many
+// sessions are connected now.
+func (p *ClientPool) SetConnected(name string, connected bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	up := 0
+	for _, m := range p.members {
+		if m.name == name {
+			m.connected = connected
+		}
+		if m.connected {
+			up++
+		}
+	}
+	return up
+}
+
+// Remove takes a session out of the rotation and returns how many are left.
+func (p *ClientPool) Remove(name string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, m := range p.members {
+		if m.name == name {
+			p.members = append(p.members[:i], p.members[i+1:]...)
+			break
+		}
+	}
+	return len(p.members)
+}
+
+// Connected reports whether any session in the rotation is connected.
+func (p *ClientPool) Connected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, m := range p.members {
+		if m.connected {
+			return true
+		}
+	}
+	return false
+}
+
+// pick returns the next connected client. If none is connected it still
+// returns one, so the call fails like it would on a single session and the
+// usual retry and reconnect handling applies.
+func (p *ClientPool) pick() (WAClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.members)
+	if n == 0 {
+		return nil, errNoSessions
+	}
+	for i := 0; i < n; i++ {
+		m := p.members[(p.next+i)%n]
+		if m.connected {
+			p.next = (p.next + i + 1) % n
+			return m.client, nil
+		}
+	}
+	m := p.members[p.next%n]
+	p.next = (p.next + 1) % n
+	return m.client, nil
+}
+
+func (p *ClientPool) IsOnWhatsApp(ctx context.Context, phones []string) ([]types.IsOnWhatsAppResponse, error) {
+	c, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	return c.IsOnWhatsApp(ctx, phones)
+}
+
+func (p *ClientPool) GetBusinessProfile(ctx context.Context, jid types.JID) (*types.BusinessProfile, error) {
+	c, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetBusinessProfile(ctx, jid)
+}
+
+func (p *ClientPool) GetUserInfo(ctx context.Context, jids []types.JID) (map[types.JID]types.UserInfo, error) {
+	c, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetUserInfo(ctx, jids)
+}
+
+func (p *ClientPool) GetProfilePictureInfo(ctx context.Context, jid types.JID, params *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error) {
+	c, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetProfilePictureInfo(ctx, jid, params)
+}
+
+func (p *ClientPool) GetContact(ctx context.Context, jid types.JID) (types.ContactInfo, error) {
+	c, err := p.pick()
+	if err != nil {
+		return types.ContactInfo{}, err
+	}
+	return c.GetContact(ctx, jid)
+}
+
+func (p *ClientPool) GetLIDForPN(ctx context.Context, pn types.JID) (types.JID, error) {
+	c, err := p.pick()
+	if err != nil {
+		return types.JID{}, err
+	}
+	return c.GetLIDForPN(ctx, pn)
+}
+
+// openSession connects an already linked -sessions database. Extra sessions
+// can't log in themselves since only one QR code can be shown; link them
+// first with -session.
+func openSession(ctx context.Context, path string, proxyURL *url.URL, roots *x509.CertPool, dbLog, clientLog waLog.Logger) (*whatsmeow.Client, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%v; link it first with wabf -session %s", err, path)
+	}
+	container, err := sqlstore.New(ctx, "sqlite3", sessionDSN(path, false), dbLog)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	deviceStore, err := container.GetFirstDevice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if deviceStore.ID == nil {
+		return nil, fmt.Errorf("%s is not logged in; link it first with wabf -session %s", path, path)
+	}
+	client := whatsmeow.NewClient(deviceStore, clientLog)
+	if proxyURL != nil {
+		if err := setClientProxy(client, proxyURL, roots); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	announcePresence(ctx, client)
+	return client, nil
+}
+
+// sessionDSN is the sqlstore database for a -session file, or an in-memory
+// one with -disable-cache.
+func sessionDSN(path string, inMemory bool) string {
+	if inMemory {
+		return "file::memory:?_foreign_keys=on"
+	}
+	return "file:" + path + "?_foreign_keys=on"
+}
+
+// presenceSender is the part of the client announcePresence uses.
+type presenceSender interface {
+	SendPresence(ctx context.Context, state types.Presence) error
+}
+
+// announcePresence marks the account as online, which WhatsApp expects from
+// an active client. -no-presence skips it so a passive account stays offline.
+func announcePresence(ctx context.Context, client presenceSender) {
+	if *noPresence {
+		return
+	}
+	client.SendPresence(ctx, types.PresenceAvailable)
+}
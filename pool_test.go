@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+func TestSessionDSN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts", "work.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	container, err := sqlstore.New(context.Background(), "sqlite3", sessionDSN(path, false), waLog.Noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("session database wasn't created at -session: %v", err)
+	}
+
+	if dsn := sessionDSN(path, true); strings.Contains(dsn, path) || !strings.Contains(dsn, ":memory:") {
+		t.Errorf("-disable-cache DSN %q should be in memory", dsn)
+	}
+}
+
+func TestOpenSessionNeedsLinkedSession(t *testing.T) {
+	ctx := context.Background()
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	if _, err := openSession(ctx, missing, nil, nil, waLog.Noop, waLog.Noop); err == nil || !strings.Contains(err.Error(), "wabf -session "+missing) {
+		t.Errorf("missing session: err = %v", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("opening a missing session created it")
+	}
+
+	// A database that was never linked has no device ID.
+	unlinked := filepath.Join(t.TempDir(), "unlinked.db")
+	container, err := sqlstore.New(ctx, "sqlite3", sessionDSN(unlinked, false), waLog.Noop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	container.Close()
+	if _, err := openSession(ctx, unlinked, nil, nil, waLog.Noop, waLog.Noop); err == nil || !strings.Contains(err.Error(), "not logged in") {
+		t.Errorf("unlinked session: err = %v", err)
+	}
+}
+
+func TestClientPoolRoundRobin(t *testing.T) {
+	a := newFakeClient("15550000001")
+	b := newFakeClient("15550000001")
+	pool := NewClientPool()
+	pool.Add("a.db", a)
+	pool.Add("b.db", b)
+
+	for i := 0; i < 6; i++ {
+		if _, err := pool.IsOnWhatsApp(context.Background(), []string{"+15550000001"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.callCount() != 3 || b.callCount() != 3 {
+		t.Errorf("calls: a %d, b %d, want 3 each", a.callCount(), b.callCount())
+	}
+
+	// A disconnected session is skipped until it is back.
+	if up := pool.SetConnected("a.db", false); up != 1 {
+		t.Errorf("%d sessions connected, want 1", up)
+	}
+	pool.IsOnWhatsApp(context.Background(), []string{"+15550000001"})
+	pool.IsOnWhatsApp(context.Background(), []string{"+15550000001"})
+	if a.callCount() != 3 || b.callCount() != 5 {
+		t.Errorf("with a disconnected: a %d, b %d calls, want 3 and 5", a.callCount(), b.callCount())
+	}
+	pool.SetConnected("a.db", true)
+
+	// A logged out session leaves the rotation for good.
+	if left := pool.Remove("b.db"); left != 1 {
+		t.Errorf("%d sessions left, want 1", left)
+	}
+	pool.IsOnWhatsApp(context.Background(), []string{"+15550000001"})
+	pool.IsOnWhatsApp(context.Background(), []string{"+15550000001"})
+	if a.callCount() != 5 || b.callCount() != 5 {
+		t.Errorf("after removing b: a %d, b %d calls, want 5 and 5", a.callCount(), b.callCount())
+	}
+
+	pool.Remove("a.db")
+	if _, err := pool.IsOnWhatsApp(context.Background(), []string{"+15550000001"}); err != errNoSessions {
+		t.Errorf("empty pool: err = %v, want errNoSessions", err)
+	}
+}
+
+func TestScannerSpreadsOverPool(t *testing.T) {
+	a := newFakeClient("15550000001", "15550000002")
+	b := newFakeClient("15550000001", "15550000002")
+	pool := NewClientPool()
+	pool.Add("a.db", a)
+	pool.Add("b.db", b)
+	s := &Scanner{Client: pool, Config: ScanConfig{NoEnrich: true}}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000001", "15550000002"}) {
+		t.Errorf("got %v", got)
+	}
+	// The LID lookups of found numbers go through the pool too, so the
+	// checks aren't split exactly in half.
+	if a.callCount()+b.callCount() != 10 || a.callCount() < 3 || b.callCount() < 3 {
+		t.Errorf("calls: a %d, b %d, want the 10 checks spread over both", a.callCount(), b.callCount())
+	}
+}
+
+type fakePresence struct {
+	sent []types.Presence
+}
+
+func (f *fakePresence) SendPresence(ctx context.Context, state types.Presence) error {
+	f.sent = append(f.sent, state)
+	return nil
+}
+
+func TestAnnouncePresence(t *testing.T) {
+	var online fakePresence
+	announcePresence(context.Background(), &online)
+	if len(online.sent) != 1 || online.sent[0] != types.PresenceAvailable {
+		t.Errorf("sent %v, want available", online.sent)
+	}
+
+	setFlag(t, noPresence, true)
+	var passive fakePresence
+	announcePresence(context.Background(), &passive)
+	if len(passive.sent) != 0 {
+		t.Errorf("sent %v with -no-presence, want nothing", passive.sent)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+const probeTimeout = 20 * time.Second
+
+// probeSession checks -probe, a number known to be on WhatsApp, before the
+// scan starts, so a logged-out, disconnected or rate-limited session fails
+// right away instead of turning a whole range into errors or misses.
+func probeSession(ctx context.Context, client WAClient, number string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	resp, err := client.IsOnWhatsApp(ctx, []string{number})
+	var discErr *whatsmeow.DisconnectedError
+	switch {
+	case errors.Is(err, whatsmeow.ErrIQRateOverLimit):
+		return fmt.Errorf("the account is rate-limited, wait a while before scanning (%v)", err)
+	case errors.Is(err, whatsmeow.ErrNotConnected), errors.As(err, &discErr), errors.Is(err, whatsmeow.ErrNotLoggedIn):
+		return fmt.Errorf("the session is not connected or was logged out (%v)", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("no answer within %s", probeTimeout)
+	case err != nil:
+		return err
+	}
+	for _, r := range resp {
+		if r.IsIn {
+			return nil
+		}
+	}
+	return fmt.Errorf("+%s was reported as not on WhatsApp; the account may be restricted, or the -probe number is wrong", number)
+}
+
+// probeSessions runs probeSession against every session in the pool,
+// connected or not, and names the first one that fails.
+func probeSessions(ctx context.Context, pool *ClientPool, number string) error {
+	pool.mu.Lock()
+	members := append([]*poolMember(nil), pool.members...)
+	pool.mu.Unlock()
+	if len(members) == 0 {
+		return errNoSessions
+	}
+	for _, m := range members {
+		if err := probeSession(ctx, m.client, number); err != nil {
+			return fmt.Errorf("session %s: %v", m.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+)
+
+func TestProbeSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  func() *fakeClient
+		wantErr string
+	}{
+		{"registered", func() *fakeClient { return newFakeClient("15550000001") }, ""},
+		{"rate limited", func() *fakeClient {
+			f := newFakeClient("15550000001")
+			f.checkErrs = []error{whatsmeow.ErrIQRateOverLimit}
+			return f
+		}, "rate-limited"},
+		{"logged out", func() *fakeClient {
+			f := newFakeClient("15550000001")
+			f.checkErrs = []error{whatsmeow.ErrNotLoggedIn}
+			return f
+		}, "logged out"},
+		{"disconnected", func() *fakeClient {
+			f := newFakeClient("15550000001")
+			f.checkErrs = []error{&whatsmeow.DisconnectedError{Action: "usync"}}
+			return f
+		}, "not connected"},
+		{"not on whatsapp", func() *fakeClient { return newFakeClient() }, "not on WhatsApp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tt.client()
+			err := probeSession(context.Background(), client, "15550000001")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("probe failed: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err %v, want one mentioning %q", err, tt.wantErr)
+			}
+			// The probe is a single lookup of the probe number, nothing more.
+			if len(client.requests) != 1 || !equalStrings(client.requests[0], []string{"15550000001"}) {
+				t.Errorf("requests %v, want only the probe number", client.requests)
+			}
+		})
+	}
+}
+
+func TestProbeNeedsFullNumber(t *testing.T) {
+	for _, probe := range []string{"1555000000x", "15abc"} {
+		if code := runArgs(t, "-dry-run", "-probe", probe, "15550000001"); code != exitUsage {
+			t.Errorf("-probe %s: exit code %d, want %d", probe, code, exitUsage)
+		}
+	}
+}
+
+func TestProbeSessions(t *testing.T) {
+	good := newFakeClient("15550000001")
+	limited := newFakeClient("15550000001")
+	limited.checkErrs = []error{whatsmeow.ErrIQRateOverLimit}
+	pool := NewClientPool()
+	pool.Add("a.db", good)
+	pool.Add("b.db", limited)
+
+	err := probeSessions(context.Background(), pool, "15550000001")
+	if err == nil || !strings.Contains(err.Error(), "b.db") {
+		t.Fatalf("err %v, want one naming the rate-limited b.db", err)
+	}
+	if good.callCount() != 1 || limited.callCount() != 1 {
+		t.Errorf("probe calls: a %d, b %d, want one per session", good.callCount(), limited.callCount())
+	}
+}
+
+func TestProbeSessionsDisconnected(t *testing.T) {
+	// The rotation skips a disconnected session, so a pool probe that went
+	// through it would check the connected one twice and pass.
+	good := newFakeClient("15550000001")
+	down := newFakeClient("15550000001")
+	down.checkErrs = []error{whatsmeow.ErrNotConnected}
+	pool := NewClientPool()
+	pool.Add("a.db", good)
+	pool.Add("b.db", down)
+	pool.SetConnected("b.db", false)
+
+	err := probeSessions(context.Background(), pool, "15550000001")
+	if err == nil || !strings.Contains(err.Error(), "b.db") || !strings.Contains(err.Error(), "not connected") {
+		t.Fatalf("err %v, want one naming the disconnected b.db", err)
+	}
+	if good.callCount() != 1 || down.callCount() != 1 {
+		t.Errorf("probe calls: a %d, b %d, want one per session", good.callCount(), down.callCount())
+	}
+}
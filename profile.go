@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+)
+
+// startProfiling starts a CPU profile and/or execution trace for -cpuprofile
+// and -trace. The returned stop writes them out; it is safe to call more
+// than once, so a forced exit can flush them before the deferred call runs.
+func startProfiling(cpuPath, tracePath string) (stop func(), err error) {
+	var files []*os.File
+	var cpu, tr bool
+	var once sync.Once
+	cleanup := func() {
+		once.Do(func() {
+			if cpu {
+				pprof.StopCPUProfile()
+			}
+			if tr {
+				trace.Stop()
+			}
+			for _, f := range files {
+				f.Close()
+			}
+		})
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %v", err)
+		}
+		files = append(files, f)
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return nil, fmt.Errorf("failed to start CPU profile: %v", err)
+		}
+		cpu = true
+	}
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create trace file: %v", err)
+		}
+		files = append(files, f)
+		if err := trace.Start(f); err != nil {
+			return nil, fmt.Errorf("failed to start trace: %v", err)
+		}
+		tr = true
+	}
+	return cleanup, nil
+}
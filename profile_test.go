@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfilingWritesFiles(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.out")
+	tracePath := filepath.Join(dir, "trace.out")
+	stop, err := startProfiling(cpuPath, tracePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0
+	for i := 0; i < 1e6; i++ {
+		sum += i
+	}
+	_ = sum
+	stop()
+	stop() // safe to call twice
+
+	for _, path := range []string{cpuPath, tracePath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() == 0 {
+			t.Errorf("%s is empty", path)
+		}
+	}
+}
+
+func TestStartProfilingBadPath(t *testing.T) {
+	stop, err := startProfiling(filepath.Join(t.TempDir(), "missing", "cpu.out"), "")
+	if err == nil {
+		t.Fatal("expected an error for a path in a missing directory")
+	}
+	if stop != nil {
+		t.Error("stop should be nil on error")
+	}
+}
+
+func TestStartProfilingCleansUpOnTraceError(t *testing.T) {
+	dir := t.TempDir()
+	_, err := startProfiling(filepath.Join(dir, "cpu.out"), filepath.Join(dir, "missing", "trace.out"))
+	if err == nil {
+		t.Fatal("expected an error for the trace path")
+	}
+	// The CPU profile must have been stopped, or starting a new one fails.
+	stop, err := startProfiling(filepath.Join(dir, "cpu2.out"), "")
+	if err != nil {
+		t.Fatalf("CPU profile left running: %v", err)
+	}
+	stop()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const progressBarWidth = 30
+
+type Progress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	tty     bool
+	color   bool
+	total   int
+	checked int
+	found   int
+	start   time.Time
+}
+
+func NewProgress(out io.Writer, tty bool, total int) *Progress {
+	return &Progress{out: out, tty: tty, total: total, start: time.Now()}
+}
+
+// Checked records one more checked number. On a terminal the bar is redrawn
+// in place; otherwise the legacy one-line-per-check output is kept.
+func (p *Progress) Checked(pn string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checked++
+	if p.tty {
+		p.draw()
+		return
+	}
+	percent := float64(p.checked) / float64(p.total) * 100
+	fmt.Fprintf(p.out, "[%3.0f%%] [ETA: %s] Checked: %-15s\n", percent, p.eta(), pn)
+}
+
+func (p *Progress) Found() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.found++
+}
+
+// Print writes text above the bar, redrawing the bar afterwards.
+func (p *Progress) Print(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+	fmt.Fprint(p.out, text)
+	if p.tty && p.checked > 0 {
+		p.draw()
+	}
+}
+
+func (p *Progress) Finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty && p.checked > 0 {
+		fmt.Fprintln(p.out)
+	}
+}
+
+func (p *Progress) draw() {
+	var sb strings.Builder
+	renderProgress(&sb, p.checked, p.total, p.found, p.eta())
+	fmt.Fprint(p.out, "\r\033[K"+paint(p.color, colorYellow, sb.String()))
+}
+
+func (p *Progress) eta() time.Duration {
+	if p.checked == 0 {
+		return 0
+	}
+	rate := float64(p.checked) / time.Since(p.start).Seconds()
+	remaining := float64(p.total - p.checked)
+	return (time.Duration(remaining/rate) * time.Second).Round(time.Second)
+}
+
+func renderProgress(w io.Writer, checked, total, found int, eta time.Duration) {
+	ratio := 1.0
+	if total > 0 {
+		ratio = float64(checked) / float64(total)
+	}
+	filled := int(ratio * progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	fmt.Fprintf(w, "[%s] %3.0f%% %d/%d | Found: %d | ETA: %s", bar, ratio*100, checked, total, found, eta)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderProgress(t *testing.T) {
+	tests := []struct {
+		checked, total int
+		want           string
+	}{
+		{0, 10, "[>                             ]   0% 0/10 | Found: 2 | ETA: 1m0s"},
+		{5, 10, "[===============>              ]  50% 5/10 | Found: 2 | ETA: 1m0s"},
+		{10, 10, "[==============================] 100% 10/10 | Found: 2 | ETA: 1m0s"},
+		{0, 0, "[==============================] 100% 0/0 | Found: 2 | ETA: 1m0s"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		renderProgress(&buf, tt.checked, tt.total, 2, time.Minute)
+		if buf.String() != tt.want {
+			t.Errorf("%d/%d:\ngot  %q\nwant %q", tt.checked, tt.total, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestProgressPiped(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, false, 4)
+	p.Checked("15550000001")
+	p.Print("[+] FOUND\n")
+	p.Finish()
+	out := buf.String()
+	if strings.Contains(out, "\r") || strings.Contains(out, "=") {
+		t.Errorf("piped output should have no bar: %q", out)
+	}
+	if !strings.HasPrefix(out, "[ 25%]") || !strings.HasSuffix(out, "Checked: 15550000001    \n[+] FOUND\n") {
+		t.Errorf("got %q", out)
+	}
+}
+
+func TestProgressTerminal(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewProgress(&buf, true, 4)
+	p.Checked("15550000001")
+	p.Found()
+	p.Print("[+] FOUND\n")
+	p.Finish()
+
+	// Found lines clear the bar, print, and redraw it below.
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], "\r\033[K[+] FOUND") {
+		t.Errorf("found line: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "1/4 | Found: 1") {
+		t.Errorf("bar not redrawn after the found line: %q", lines[1])
+	}
+}
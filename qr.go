@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mdp/qrterminal/v3"
+	"go.mau.fi/whatsmeow"
+	"rsc.io/qr"
+)
+
+// qrPNGScale is the size in pixels of one QR module in -qr-out images.
+const qrPNGScale = 8
+
+// showQR presents a login code: as a PNG file with -qr-out, as large
+// two-character ASCII modules with -qr-ascii, otherwise as half blocks.
+func showQR(code string, w io.Writer) error {
+	switch {
+	case *qrOut != "":
+		if err := writeQRPNG(code, *qrOut); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "QR code written to %s, open it and scan it to log in\n", *qrOut)
+	case *qrASCII:
+		qrterminal.GenerateWithConfig(code, qrterminal.Config{
+			Level:     qrterminal.L,
+			Writer:    w,
+			BlackChar: "  ",
+			WhiteChar: "##",
+			QuietZone: 2,
+		})
+		fmt.Fprintln(w, "Scan the QR code to log in")
+	default:
+		qrterminal.GenerateHalfBlock(code, qrterminal.L, w)
+		fmt.Fprintln(w, "Scan the QR code to log in")
+	}
+	return nil
+}
+
+func writeQRPNG(code, path string) error {
+	c, err := qr.Encode(code, qr.L)
+	if err != nil {
+		return err
+	}
+	c.Scale = qrPNGScale
+	return os.WriteFile(path, c.PNG(), 0600)
+}
+
+// pairer is the part of the client that requests a pairing code.
+type pairer interface {
+	PairPhone(ctx context.Context, phone string, showPushNotification bool, clientType whatsmeow.PairClientType, clientDisplayName string) (string, error)
+}
+
+// loginPrompt shows the user how to link this session for each code from
+// the QR channel: with a phone number (-pair-phone) it requests a pairing
+// code once, on the first event as whatsmeow requires, and hides the QR
+// codes; otherwise it draws the QR code.
+type loginPrompt struct {
+	client pairer
+	phone  string
+	w      io.Writer
+	paired bool
+}
+
+func (p *loginPrompt) Code(ctx context.Context, code string) error {
+	if p.phone == "" {
+		return showQR(code, p.w)
+	}
+	if p.paired {
+		return nil
+	}
+	p.paired = true
+	pairCode, err := p.client.PairPhone(ctx, p.phone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(p.w, "Pairing code: %s\n", pairCode)
+	fmt.Fprintln(p.w, "On your phone open WhatsApp > Linked devices > Link a device > Link with phone number instead, and enter the code.")
+	return nil
+}
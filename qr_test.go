@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+)
+
+const testQRCode = "2@fakeRef,fakeNoiseKey,fakeIdentityKey,fakeAdvSecret"
+
+func TestShowQRPNG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "login.png")
+	setFlag(t, qrOut, path)
+	var out bytes.Buffer
+	if err := showQR(testQRCode, &out); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("%s is not a PNG: %v", path, err)
+	}
+	if b := img.Bounds(); b.Dx() != b.Dy() || b.Dx()%qrPNGScale != 0 {
+		t.Errorf("image is %dx%d, want a square of %dpx modules", b.Dx(), b.Dy(), qrPNGScale)
+	}
+	if !strings.Contains(out.String(), path) {
+		t.Errorf("output doesn't say where the QR code is: %q", out.String())
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("QR image mode %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestShowQRTerminal(t *testing.T) {
+	setFlag(t, qrOut, "")
+	for _, ascii := range []bool{false, true} {
+		setFlag(t, qrASCII, ascii)
+		var out bytes.Buffer
+		if err := showQR(testQRCode, &out); err != nil {
+			t.Fatal(err)
+		}
+		hasBlocks := strings.ContainsAny(out.String(), "▀▄█")
+		hasASCII := strings.Contains(out.String(), "##")
+		if hasBlocks == ascii || hasASCII != ascii {
+			t.Errorf("-qr-ascii=%v: blocks %v, ASCII %v", ascii, hasBlocks, hasASCII)
+		}
+	}
+}
+
+type fakePairer struct {
+	calls int
+	phone string
+}
+
+func (f *fakePairer) PairPhone(ctx context.Context, phone string, showPushNotification bool, clientType whatsmeow.PairClientType, clientDisplayName string) (string, error) {
+	f.calls++
+	f.phone = phone
+	return "ABCD-EFGH", nil
+}
+
+func TestLoginPrompt(t *testing.T) {
+	setFlag(t, qrOut, "")
+	setFlag(t, qrASCII, true)
+
+	// With -pair-phone the code is requested once and no QR is drawn.
+	pairer := &fakePairer{}
+	var out bytes.Buffer
+	p := &loginPrompt{client: pairer, phone: "15551234567", w: &out}
+	for i := 0; i < 3; i++ {
+		if err := p.Code(context.Background(), testQRCode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pairer.calls != 1 || pairer.phone != "15551234567" {
+		t.Errorf("PairPhone called %d times with %q, want once with 15551234567", pairer.calls, pairer.phone)
+	}
+	if !strings.Contains(out.String(), "Pairing code: ABCD-EFGH") || strings.Contains(out.String(), "##") {
+		t.Errorf("pairing output:\n%s", out.String())
+	}
+
+	// Without it every code is shown as a QR.
+	pairer = &fakePairer{}
+	out.Reset()
+	p = &loginPrompt{client: pairer, w: &out}
+	if err := p.Code(context.Background(), testQRCode); err != nil {
+		t.Fatal(err)
+	}
+	if pairer.calls != 0 || !strings.Contains(out.String(), "##") {
+		t.Errorf("QR fallback: %d pairing calls, output:\n%s", pairer.calls, out.String())
+	}
+
+	if code := runArgs(t, "-pair-phone", "1555xxx", "15550000001"); code != exitUsage {
+		t.Errorf("-pair-phone with a pattern: exit code %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// normalizePattern cleans a pattern as typed by the user and, with -region,
+// rewrites a local-format number or pattern to international form.
+func normalizePattern(raw string) string {
+	pattern := cleanPattern(raw)
+	if *region == "" || pattern == "" || strings.HasPrefix(strings.TrimSpace(raw), "+") {
+		return pattern
+	}
+	return localToInternational(pattern, strings.ToUpper(*region))
+}
+
+// localToInternational uses libphonenumber on a representative number (each
+// wildcard replaced by its first option) to find the country code and how
+// many leading digits are a trunk or international dialling prefix, then
+// applies the same rewrite to the pattern. Patterns it can't make sense of
+// are returned unchanged.
+func localToInternational(pattern, region string) string {
+	sample := pattern
+	if hasWildcards(pattern) {
+		parts, fills, err := parsePattern(pattern)
+		if err != nil {
+			return pattern
+		}
+		var sb strings.Builder
+		for i, fill := range fills {
+			if len(fill) == 0 {
+				return pattern
+			}
+			sb.WriteString(parts[i])
+			sb.WriteString(fill[0])
+		}
+		sb.WriteString(parts[len(fills)])
+		sample = sb.String()
+	}
+
+	num, err := phonenumbers.Parse(sample, region)
+	if err != nil {
+		return pattern
+	}
+	national := phonenumbers.GetNationalSignificantNumber(num)
+	if !strings.HasSuffix(sample, national) {
+		return pattern
+	}
+	// The dropped prefix must sit in the literal digits before the first
+	// wildcard, otherwise stripping it would change what the pattern means.
+	strip := len(sample) - len(national)
+	literal := len(pattern)
+	if i := strings.IndexAny(pattern, "x[("); i >= 0 {
+		literal = i
+	}
+	if strip > literal {
+		return pattern
+	}
+	return strconv.Itoa(int(num.GetCountryCode())) + pattern[strip:]
+}
+
+func validateRegion(region string) error {
+	if phonenumbers.GetCountryCodeForRegion(strings.ToUpper(region)) == 0 {
+		return fmt.Errorf("unknown region %q (use an ISO country code like US or GB)", region)
+	}
+	return nil
+}
+
+// countryOf names the country a found number belongs to: its ISO region
+// when libphonenumber knows it, otherwise just the calling code.
+func countryOf(pn string) string {
+	num, err := phonenumbers.Parse("+"+strings.TrimPrefix(pn, "+"), "")
+	if err != nil {
+		return "unknown"
+	}
+	if region := phonenumbers.GetRegionCodeForNumber(num); region != "" && region != "ZZ" && region != "001" {
+		return region
+	}
+	return "+" + strconv.Itoa(int(num.GetCountryCode()))
+}
+
+// e164Phone formats a found number as strict E.164 for -normalize-output.
+// Spaces, dashes and any leading + are dropped; numbers libphonenumber
+// can't parse keep their digits behind a single +.
+func e164Phone(pn string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, pn)
+	if digits == "" {
+		return pn
+	}
+	num, err := phonenumbers.Parse("+"+digits, "")
+	if err != nil {
+		return "+" + digits
+	}
+	return phonenumbers.Format(num, phonenumbers.E164)
+}
+
+// trunkZeroCodes are country codes that dial a 0 trunk prefix nationally
+// but never have one after the country code, so +44 0... can't exist.
+var trunkZeroCodes = map[string]bool{
+	"7": true, "20": true, "27": true, "31": true, "32": true, "33": true, "34": true,
+	"41": true, "43": true, "44": true, "46": true, "48": true, "49": true, "55": true,
+	"61": true, "62": true, "63": true, "64": true, "66": true, "81": true, "82": true,
+	"84": true, "86": true, "90": true, "91": true, "92": true, "234": true,
+}
+
+// saneNumber is the -sane check: a length every real number has, no
+// leading 0, no 0 right after a country code that can't have one, and no
+// 0 or 1 starting a North American area code. It doesn't need
+// libphonenumber's metadata, so it's far cheaper than -validate.
+func saneNumber(pn string) bool {
+	if len(pn) < 7 || len(pn) > 15 || pn[0] == '0' {
+		return false
+	}
+	if pn[0] == '1' {
+		return pn[1] != '0' && pn[1] != '1'
+	}
+	for n := 1; n <= 3; n++ {
+		if trunkZeroCodes[pn[:n]] {
+			return pn[n] != '0'
+		}
+	}
+	return true
+}
+
+// prettyPhone formats pn the way it is usually written internationally,
+// e.g. +1 555-123-4567, for -output-format pretty.
+func prettyPhone(pn string) string {
+	num, err := phonenumbers.Parse("+"+pn, "")
+	if err != nil {
+		return "+" + pn
+	}
+	return phonenumbers.Format(num, phonenumbers.INTERNATIONAL)
+}
+
+type countryCount struct {
+	Country string
+	Found   int
+}
+
+// countByCountry groups results by countryOf, most found first.
+func countByCountry(results []ScanResult) []countryCount {
+	counts := make(map[string]int)
+	for _, res := range results {
+		if res.Found {
+			counts[countryOf(res.Phone)]++
+		}
+	}
+	var out []countryCount
+	for country, n := range counts {
+		out = append(out, countryCount{country, n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Found != out[j].Found {
+			return out[i].Found > out[j].Found
+		}
+		return out[i].Country < out[j].Country
+	})
+	return out
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePatternRegion(t *testing.T) {
+	tests := []struct {
+		region, raw, want string
+	}{
+		{"GB", "07700 900123", "447700900123"},
+		{"gb", "07700 900xxx", "447700900xxx"},
+		{"GB", "0044 7700 900123", "447700900123"},
+		{"GB", "+44 7700 900123", "447700900123"},
+		{"US", "650 253 0000", "16502530000"},
+		{"US", "650253xxxx", "1650253xxxx"},
+		{"DE", "030 1234567[0-4]", "49301234567[0-4]"},
+		// The trunk zero is a wildcard, so the pattern can't be rewritten.
+		{"GB", "[07]7700900123", "[07]7700900123"},
+		{"", "07700 900123", "07700900123"},
+	}
+	for _, tt := range tests {
+		setFlag(t, region, tt.region)
+		if got := normalizePattern(tt.raw); got != tt.want {
+			t.Errorf("-region %q, %q: got %s, want %s", tt.region, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRegion(t *testing.T) {
+	for _, r := range []string{"US", "gb", "DE"} {
+		if err := validateRegion(r); err != nil {
+			t.Errorf("%s: %v", r, err)
+		}
+	}
+	for _, r := range []string{"XX", "USA", ""} {
+		if err := validateRegion(r); err == nil {
+			t.Errorf("%q: expected an error", r)
+		}
+	}
+}
+
+func TestCountByCountry(t *testing.T) {
+	results := []ScanResult{
+		{Phone: "16502530000", Found: true},
+		{Phone: "14155550100", Found: true},
+		{Phone: "442071838750", Found: true},
+		{Phone: "+4915123456789", Found: true},
+		{Phone: "4930123456", Found: true},
+		{Phone: "447400123456", Found: true},
+		{Phone: "16502530001", Found: false},
+		// A calling code libphonenumber doesn't map to one region.
+		{Phone: "8001234567", Found: true},
+	}
+	got := countByCountry(results)
+	want := []countryCount{{"DE", 2}, {"GB", 2}, {"US", 2}, {"+800", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestE164Phone(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"15551234567", "+15551234567"},
+		{"+15551234567", "+15551234567"},
+		{"++1 (555) 123-4567", "+15551234567"},
+		{" +44 20 7183 8750 ", "+442071838750"},
+		{"49-151-2345-6789", "+4915123456789"},
+		// Numbers libphonenumber can't parse keep their digits.
+		{"999", "+999"},
+		{"", ""},
+		{"---", "---"},
+	}
+	for _, tt := range tests {
+		if got := e164Phone(tt.in); got != tt.want {
+			t.Errorf("e164Phone(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	// The vCard TEL never gets a second +, normalized or not.
+	for _, phone := range []string{"15551234567", "+15551234567", e164Phone("+1 555 123 4567")} {
+		card := formatVCard(ScanResult{Phone: phone})
+		if !strings.Contains(card, "TEL;TYPE=CELL:+15551234567\n") {
+			t.Errorf("%q: vCard\n%s", phone, card)
+		}
+	}
+}
+
+func TestPrettyPhone(t *testing.T) {
+	tests := []struct{ pn, want string }{
+		{"15551234567", "+1 555-123-4567"},
+		{"447400123456", "+44 7400 123456"},
+		{"4915123456789", "+49 1512 3456789"},
+		{"5511987654321", "+55 11 98765-4321"},
+		{"919876543210", "+91 98765 43210"},
+		{"33612345678", "+33 6 12 34 56 78"},
+		// Numbers libphonenumber can't place are shown as plain E.164.
+		{"999123", "+999123"},
+	}
+	for _, tt := range tests {
+		if got := prettyPhone(tt.pn); got != tt.want {
+			t.Errorf("prettyPhone(%s) = %q, want %q", tt.pn, got, tt.want)
+		}
+		if got := formatOutput(tt.pn+"@c.us", "pretty"); got != tt.want {
+			t.Errorf("formatOutput(%s, pretty) = %q, want %q", tt.pn, got, tt.want)
+		}
+	}
+}
+
+func TestSaneNumber(t *testing.T) {
+	tests := []struct {
+		pn   string
+		want bool
+	}{
+		{"15551234567", true},
+		{"447400123456", true},
+		{"4915123456789", true},
+		// No trunk-zero rule for 39: Italian landlines keep their 0.
+		{"390612345678", true},
+		{"123456", false},           // too short
+		{"1234567890123456", false}, // too long
+		{"05551234567", false},      // no country code starts with 0
+		{"10551234567", false},      // NANP area codes can't start with 0
+		{"11551234567", false},      // or 1
+		{"4407400123456", false},    // trunk zero after +44
+		{"49015123456789", false},   // after +49
+		{"2340803123456", false},    // after a three-digit code
+	}
+	for _, tt := range tests {
+		if got := saneNumber(tt.pn); got != tt.want {
+			t.Errorf("saneNumber(%s) = %v, want %v", tt.pn, got, tt.want)
+		}
+	}
+}
+
+func TestSaneFilter(t *testing.T) {
+	var kept []string
+	dropped := 0
+	fn := withValidation(func(jid string) bool {
+		kept = append(kept, jid)
+		return true
+	}, &dropped, false, true)
+	if err := walkPattern("44[07]740012345x", fn); err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 10 || dropped != 10 {
+		t.Errorf("kept %d and dropped %d, want 10 of each", len(kept), dropped)
+	}
+	for _, jid := range kept {
+		if strings.HasPrefix(jid, "440") {
+			t.Errorf("kept %s", jid)
+		}
+	}
+
+	got := dryRunNumbers(t, "-sane", "44[07]7400123456", "15551234567")
+	if want := []string{"4477400123456", "15551234567"}; !equalStrings(got, want) {
+		t.Errorf("-sane dry run: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,714 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"golang.org/x/time/rate"
+)
+
+// WAClient is the part of the WhatsApp client a scan needs. It is satisfied
+// by NewWAClient for a live session and can be faked in tests.
+type WAClient interface {
+	IsOnWhatsApp(ctx context.Context, phones []string) ([]types.IsOnWhatsAppResponse, error)
+	GetBusinessProfile(ctx context.Context, jid types.JID) (*types.BusinessProfile, error)
+	GetUserInfo(ctx context.Context, jids []types.JID) (map[types.JID]types.UserInfo, error)
+	GetProfilePictureInfo(ctx context.Context, jid types.JID, params *whatsmeow.GetProfilePictureParams) (*types.ProfilePictureInfo, error)
+	GetContact(ctx context.Context, jid types.JID) (types.ContactInfo, error)
+	GetLIDForPN(ctx context.Context, pn types.JID) (types.JID, error)
+}
+
+type waClient struct {
+	*whatsmeow.Client
+}
+
+func NewWAClient(client *whatsmeow.Client) WAClient {
+	return waClient{client}
+}
+
+func (c waClient) GetContact(ctx context.Context, jid types.JID) (types.ContactInfo, error) {
+	return c.Store.Contacts.GetContact(ctx, jid)
+}
+
+func (c waClient) GetLIDForPN(ctx context.Context, pn types.JID) (types.JID, error) {
+	return c.Store.LIDs.GetLIDForPN(ctx, pn)
+}
+
+// ScanConfig holds the options that shape a scan; scanConfigFromFlags fills
+// it from the command line. The zero value checks numbers back to back, with
+// no retries and full enrichment.
+type ScanConfig struct {
+	Delay        time.Duration
+	Jitter       time.Duration
+	Retries      int
+	RetryBackoff time.Duration
+	Shuffle      bool
+	Seed         int64
+	Validate     bool
+	Sane         bool
+	NotFound     bool
+	VerifiedOnly bool
+	NoEnrich     bool
+	OnlyBusiness bool
+	HasAvatar    bool
+	FullAvatar   bool
+	FollowAvatar bool
+	// AvatarDir is where avatars are saved; empty means they aren't.
+	AvatarDir   string
+	StatusMatch *regexp.Regexp
+	Verbose     bool
+}
+
+// Scanner checks the numbers matched by a set of patterns: a pool of
+// existence workers feeds found numbers to a separate enrichment pool so
+// slow profile lookups don't hold up existence checks.
+type Scanner struct {
+	Client        WAClient
+	Config        ScanConfig
+	Concurrency   int
+	BatchSize     int
+	EnrichWorkers int
+	Limiter       *rate.Limiter
+	// Cache, Adaptive, Gate and Metrics are optional.
+	Cache    *LookupCache
+	Adaptive *AdaptiveDelay
+	Gate     *ConnGate
+	Metrics  *Metrics
+	// Skip holds JIDs that are not checked again (e.g. from -resume).
+	Skip map[string]bool
+	// ResultBuffer is the capacity of the channel returned by Start
+	// (0 = resultsPerWorker per enrichment worker). Keep it small: a full
+	// channel makes the workers, and in turn generation, wait for the
+	// consumer instead of piling results up in memory.
+	ResultBuffer int
+	// MaxFound stops the scan once this many results have been emitted
+	// (0 = no limit). Numbers already found beyond it are dropped.
+	MaxFound int
+	// Options holds per-line overrides from pattern files: Options[i]
+	// applies to the i-th pattern passed to Start, and patterns past its end
+	// have none. Consecutive patterns with the same options are scanned
+	// together, one such group after another.
+	Options []PatternOptions
+
+	// OnChecked is called from the worker goroutines after each batch,
+	// with the JIDs checked and the round-trip time of the request (0 if
+	// it was answered from the cache).
+	OnChecked func(batch []string, elapsed time.Duration)
+	// OnDone is called with the JID of each number that was answered but
+	// produced no result: not on WhatsApp, or found and then filtered out
+	// or collapsed as a duplicate. Numbers that do produce a result are
+	// only done once the caller has saved it, and failed or unanswered
+	// ones never are, so a resumed scan checks them again.
+	OnDone func(jid string)
+	// OnFailed is called for each number whose check failed for a reason
+	// other than the scan being cancelled.
+	OnFailed func(pn string, err error)
+	// OnDuplicate is called for a found number that resolves to an account
+	// already reported under another number; it isn't emitted.
+	OnDuplicate func(res ScanResult)
+
+	emitted  int64
+	seenMu   sync.Mutex
+	accounts map[string]bool
+}
+
+// Start scans patterns in the background. The returned channel is closed
+// once every number has been checked and enriched, or ctx is done;
+// enrichment of numbers already found finishes even after cancellation.
+func (s *Scanner) Start(ctx context.Context, patterns []string) <-chan ScanResult {
+	ctx, stop := context.WithCancel(ctx)
+	enrichWorkers := max(s.EnrichWorkers, 1)
+
+	enrichChan := make(chan foundNumber, enrichQueueSize)
+	resultBuffer := s.ResultBuffer
+	if resultBuffer <= 0 {
+		resultBuffer = resultsPerWorker * enrichWorkers
+	}
+	resultChan := make(chan ScanResult, resultBuffer)
+
+	var enrichWG sync.WaitGroup
+	for w := 0; w < enrichWorkers; w++ {
+		enrichWG.Add(1)
+		go func() {
+			defer enrichWG.Done()
+			enrichCtx := context.WithoutCancel(ctx)
+			for f := range enrichChan {
+				if s.ReachedMax() {
+					continue
+				}
+				res := s.enrichResult(enrichCtx, f.Phone, f.Resp)
+				if res == nil || s.duplicate(*res) {
+					if s.OnDone != nil {
+						s.OnDone(f.Phone + "@c.us")
+					}
+					continue
+				}
+				if s.MaxFound > 0 && res.Found {
+					n := atomic.AddInt64(&s.emitted, 1)
+					if n > int64(s.MaxFound) {
+						continue
+					}
+					if n == int64(s.MaxFound) {
+						stop()
+					}
+				}
+				res.Duration = f.Elapsed
+				resultChan <- *res
+			}
+		}()
+	}
+
+	go func() {
+		phases := s.phases(patterns)
+		// Patterns in different phases are walked separately, so
+		// duplicates across them are dropped here instead.
+		var seen map[string]bool
+		if len(phases) > 1 {
+			seen = make(map[string]bool)
+		}
+		for _, ph := range phases {
+			if ctx.Err() != nil {
+				break
+			}
+			s.check(ctx, ph, seen, enrichChan)
+		}
+		close(enrichChan)
+		enrichWG.Wait()
+		close(resultChan)
+		stop()
+	}()
+
+	return resultChan
+}
+
+// scanPhase is a run of consecutive patterns sharing the same options.
+type scanPhase struct {
+	patterns []string
+	opts     PatternOptions
+}
+
+func (s *Scanner) phases(patterns []string) []scanPhase {
+	var phases []scanPhase
+	for i, p := range patterns {
+		var opts PatternOptions
+		if i < len(s.Options) {
+			opts = s.Options[i]
+		}
+		if n := len(phases); n > 0 && phases[n-1].opts.equal(opts) {
+			phases[n-1].patterns = append(phases[n-1].patterns, p)
+			continue
+		}
+		phases = append(phases, scanPhase{patterns: []string{p}, opts: opts})
+	}
+	return phases
+}
+
+// check runs the existence workers for one phase and sends what they find to
+// enrichChan, returning once every number in it has been checked.
+func (s *Scanner) check(ctx context.Context, ph scanPhase, seen map[string]bool, enrichChan chan<- foundNumber) {
+	concurrency := max(s.Concurrency, 1)
+	if ph.opts.Concurrency > 0 {
+		concurrency = ph.opts.Concurrency
+	}
+	batchSize := max(s.BatchSize, 1)
+	pace := ph.opts.pacing(pacing{delay: s.Config.Delay, jitter: s.Config.Jitter})
+
+	jidChan := make(chan string, concurrency*batchSize)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				batch := nextBatch(jidChan, batchSize)
+				if len(batch) == 0 || ctx.Err() != nil {
+					return
+				}
+
+				if s.Limiter != nil {
+					if err := s.Limiter.Wait(ctx); err != nil {
+						return
+					}
+				}
+				if err := s.waitConnected(ctx); err != nil {
+					return
+				}
+
+				found, answered, failed, elapsed, err := s.checkJIDs(ctx, batch, pace)
+				if err != nil && ctx.Err() != nil && len(answered) == 0 {
+					return
+				}
+				for pn, checkErr := range failed {
+					if errors.Is(checkErr, context.Canceled) || errors.Is(checkErr, context.DeadlineExceeded) {
+						continue
+					}
+					if s.OnFailed != nil {
+						s.OnFailed(pn, checkErr)
+					}
+				}
+				if s.OnChecked != nil {
+					s.OnChecked(batch, elapsed)
+				}
+				if s.OnDone != nil {
+					pending := make(map[string]bool, len(found))
+					for _, f := range found {
+						pending[f.Phone] = true
+					}
+					for _, pn := range answered {
+						if !pending[pn] {
+							s.OnDone(pn + "@c.us")
+						}
+					}
+				}
+				for _, f := range found {
+					enrichChan <- f
+				}
+			}
+		}()
+	}
+
+	forEachJID(ph.patterns, s.Config.Shuffle, s.Config.Seed, withValidation(func(jid string) bool {
+		if s.Skip[jid] {
+			return true
+		}
+		if seen != nil {
+			if seen[jid] {
+				return true
+			}
+			seen[jid] = true
+		}
+		select {
+		case jidChan <- jid:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, nil, s.Config.Validate, s.Config.Sane))
+	close(jidChan)
+	wg.Wait()
+}
+
+// duplicate reports whether res is an account already emitted under another
+// number (e.g. with and without a trunk zero), recording it if not.
+func (s *Scanner) duplicate(res ScanResult) bool {
+	if !res.Found || res.ResolvedJID == "" {
+		return false
+	}
+	s.seenMu.Lock()
+	defer s.seenMu.Unlock()
+	if s.accounts[res.ResolvedJID] {
+		if s.OnDuplicate != nil {
+			s.OnDuplicate(res)
+		}
+		return true
+	}
+	if s.accounts == nil {
+		s.accounts = make(map[string]bool)
+	}
+	s.accounts[res.ResolvedJID] = true
+	return false
+}
+
+// waitConnected blocks while Gate reports the connection as down.
+func (s *Scanner) waitConnected(ctx context.Context) error {
+	if s.Gate == nil {
+		return nil
+	}
+	return s.Gate.Wait(ctx)
+}
+
+// ReachedMax reports whether the scan stopped because of MaxFound.
+func (s *Scanner) ReachedMax() bool {
+	return s.MaxFound > 0 && atomic.LoadInt64(&s.emitted) >= int64(s.MaxFound)
+}
+
+// Run scans patterns and returns every found account. If ctx ends first,
+// the results found so far are returned along with ctx's error.
+func (s *Scanner) Run(ctx context.Context, patterns []string) ([]ScanResult, error) {
+	var results []ScanResult
+	for res := range s.Start(ctx, patterns) {
+		results = append(results, res)
+	}
+	if s.ReachedMax() {
+		return results, nil
+	}
+	return results, ctx.Err()
+}
+
+const (
+	enrichQueueSize  = 1024
+	resultsPerWorker = 4
+)
+
+// foundNumber is a number IsOnWhatsApp (or the lookup cache) reported as
+// registered, waiting to be enriched.
+type foundNumber struct {
+	Phone   string
+	Resp    types.IsOnWhatsAppResponse
+	Elapsed time.Duration
+}
+
+// checkJIDs checks a batch of JIDs and returns the ones on WhatsApp, plus
+// with -include-not-found the ones that aren't (Resp.IsIn false). answered
+// holds every number that got an answer, found or not. Numbers whose check
+// failed are returned in failed, keyed by phone number, so they can be told
+// apart from numbers that simply aren't registered.
+func (s *Scanner) checkJIDs(ctx context.Context, jids []string, pace pacing) (found []foundNumber, answered []string, failed map[string]error, elapsed time.Duration, err error) {
+	var pns []string
+	for _, jid := range jids {
+		if pn := strings.TrimSuffix(jid, "@c.us"); pn != "" {
+			pns = append(pns, pn)
+		}
+	}
+	if len(pns) == 0 {
+		return nil, nil, nil, 0, nil
+	}
+
+	// Answer what we can from the lookup cache; only the rest go to the network.
+	var resp []types.IsOnWhatsAppResponse
+	cfg := s.Config
+	if s.Cache != nil {
+		var uncached []string
+		for _, pn := range pns {
+			entry, ok, err := s.Cache.Get(pn)
+			if err != nil && cfg.Verbose {
+				slog.Warn("Failed to read lookup cache", "event", "cache", "phone", pn, "error", err)
+			}
+			// Cached answers don't keep the verified name, so found numbers
+			// are re-checked when filtering on it.
+			if !ok || (entry.OnWhatsApp && cfg.VerifiedOnly) {
+				uncached = append(uncached, pn)
+				continue
+			}
+			if cfg.Verbose {
+				slog.Info("Lookup cache hit", "event", "cache", "phone", pn, "on_whatsapp", entry.OnWhatsApp)
+			}
+			jid, _ := types.ParseJID(entry.JID)
+			resp = append(resp, types.IsOnWhatsAppResponse{Query: pn, JID: jid, IsIn: entry.OnWhatsApp})
+		}
+		pns = uncached
+	}
+
+	if len(pns) > 0 {
+		var fresh []types.IsOnWhatsAppResponse
+		fresh, failed, elapsed, err = s.lookupNumbers(ctx, pns, pace)
+		if s.Metrics != nil {
+			if elapsed > 0 {
+				s.Metrics.Latency.Observe(elapsed.Seconds())
+			}
+			if err != nil && ctx.Err() == nil {
+				s.Metrics.Errors.Inc()
+			}
+		}
+		if s.Adaptive != nil && ctx.Err() == nil {
+			if err != nil {
+				backoff := s.Adaptive.Failure()
+				if cfg.Verbose {
+					slog.Warn("Check failed, increasing delay", "event", "adaptive", "delay", backoff.String())
+				}
+			} else {
+				s.Adaptive.Success()
+			}
+		}
+		if err != nil && len(fresh) == 0 && len(resp) == 0 {
+			return nil, nil, failed, elapsed, err
+		}
+		if s.Cache != nil {
+			for _, r := range fresh {
+				jid := ""
+				if r.IsIn {
+					jid = r.JID.String()
+				}
+				if cacheErr := s.Cache.Put(strings.TrimPrefix(r.Query, "+"), r.IsIn, jid); cacheErr != nil && cfg.Verbose {
+					slog.Warn("Failed to update lookup cache", "event", "cache", "phone", r.Query, "error", cacheErr)
+				}
+			}
+		}
+		resp = append(resp, fresh...)
+	}
+
+	for _, r := range resp {
+		pn := strings.TrimPrefix(r.Query, "+")
+		answered = append(answered, pn)
+		if !r.IsIn && !cfg.NotFound {
+			continue
+		}
+		if r.IsIn && cfg.VerifiedOnly && verifiedName(r) == "" {
+			continue
+		}
+		found = append(found, foundNumber{Phone: pn, Resp: r, Elapsed: elapsed})
+	}
+	return found, answered, failed, elapsed, err
+}
+
+func (s *Scanner) lookupNumbers(ctx context.Context, pns []string, pace pacing) ([]types.IsOnWhatsAppResponse, map[string]error, time.Duration, error) {
+	wait := pace.delay
+	if s.Adaptive != nil {
+		wait = max(wait, s.Adaptive.Delay())
+	}
+	select {
+	case <-ctx.Done():
+		return nil, nil, 0, ctx.Err()
+	case <-time.After(wait + jitterDelay(pace.jitter)):
+	}
+
+	resp, rtt, err := s.isOnWhatsAppWithRetry(ctx, pns)
+	if err != nil && len(pns) > 1 && ctx.Err() == nil {
+		// Don't let one bad batch lose every number in it; fall back to
+		// checking them one at a time.
+		if s.Config.Verbose {
+			slog.Warn("Batch check failed, checking individually", "event", "check_error", "count", len(pns), "error", err)
+		}
+		resp, err = nil, nil
+		failed := make(map[string]error)
+		var total time.Duration
+		for _, pn := range pns {
+			single, singleRTT, singleErr := s.isOnWhatsAppWithRetry(ctx, []string{pn})
+			total += singleRTT
+			if singleErr != nil {
+				if s.Config.Verbose {
+					slog.Warn("Error checking number", "event", "check_error", "phone", pn, "error", singleErr)
+				}
+				failed[pn] = singleErr
+				err = singleErr
+				continue
+			}
+			resp = append(resp, single...)
+		}
+		return resp, failed, total / time.Duration(len(pns)), err
+	} else if err != nil {
+		if s.Config.Verbose {
+			slog.Warn("Error checking number", "event", "check_error", "phone", strings.Join(pns, ","), "error", err)
+		}
+		failed := make(map[string]error, len(pns))
+		for _, pn := range pns {
+			failed[pn] = err
+		}
+		return nil, failed, rtt, err
+	}
+	return resp, nil, rtt, nil
+}
+
+func (s *Scanner) enrichResult(ctx context.Context, pn string, resp types.IsOnWhatsAppResponse) *ScanResult {
+	cfg, client := s.Config, s.Client
+	if !resp.IsIn {
+		return &ScanResult{JID: pn + "@c.us", Phone: pn}
+	}
+	res := &ScanResult{
+		JID:   pn + "@c.us",
+		Phone: pn,
+		Link:  "https://wa.me/" + strings.ReplaceAll(strings.ReplaceAll(pn, " ", ""), "+", ""),
+		Found: true,
+	}
+
+	targetJID, _ := types.ParseJID(resp.JID.String())
+	res.ResolvedJID = targetJID.String()
+
+	// The LID mapping comes from the local store, so it costs no request.
+	if lid, err := client.GetLIDForPN(ctx, targetJID); err == nil && !lid.IsEmpty() {
+		res.LID = lid.String()
+	}
+
+	if cfg.NoEnrich {
+		res.VerifiedName = verifiedName(resp)
+		res.CertIssuer, res.CertSerial = verifiedCert(resp)
+		if res.VerifiedName != "" {
+			res.Type = "business"
+		}
+		return res
+	}
+
+	biz, err := client.GetBusinessProfile(ctx, targetJID)
+	if err == nil {
+		res.Business = biz
+	}
+	res.Type = accountType(res.Business, verifiedName(resp))
+	if cfg.OnlyBusiness && res.Type != "business" {
+		return nil
+	}
+
+	if pic, size := s.fetchAvatar(ctx, targetJID); pic != nil {
+		res.AvatarURL = pic.URL
+		res.AvatarID = pic.ID
+		res.AvatarSize = size
+	}
+	if cfg.HasAvatar && res.AvatarURL == "" {
+		return nil
+	}
+
+	contact, err := client.GetContact(ctx, targetJID)
+	if err == nil && contact.Found {
+		res.Name = contact.FullName
+		res.PushName = contact.PushName
+		if res.Name == "" {
+			res.Name = contact.PushName
+		}
+	}
+
+	userInfo, err := client.GetUserInfo(ctx, []types.JID{targetJID})
+	if err == nil {
+		if info, ok := userInfo[targetJID]; ok {
+			res.Status = info.Status
+			res.VerifiedName = verifiedName(resp)
+			res.CertIssuer, res.CertSerial = verifiedCert(resp)
+			if res.LID == "" && !info.LID.IsEmpty() {
+				res.LID = info.LID.String()
+			}
+			for _, device := range info.Devices {
+				res.Devices = append(res.Devices, device.String())
+			}
+		}
+	}
+
+	if cfg.StatusMatch != nil && !cfg.StatusMatch.MatchString(res.Status) {
+		return nil
+	}
+
+	if cfg.AvatarDir != "" && res.AvatarURL != "" {
+		path, final, err := downloadFile(res.AvatarURL, filepath.Join(cfg.AvatarDir, pn))
+		res.AvatarFinal = final
+		if err == nil {
+			res.AvatarPath = path
+		} else if cfg.Verbose {
+			slog.Warn("Failed to download avatar", "event", "avatar", "phone", pn, "error", err)
+		}
+	} else if cfg.FollowAvatar && res.AvatarURL != "" {
+		final, err := resolveURL(res.AvatarURL)
+		if err == nil {
+			res.AvatarFinal = final
+		} else if cfg.Verbose {
+			slog.Warn("Failed to resolve avatar URL", "event", "avatar", "phone", pn, "error", err)
+		}
+	}
+
+	return res
+}
+
+// fetchAvatar gets the full-size picture (whatsmeow's "image" type), or the
+// preview thumbnail with -full-avatar=false or when the full one can't be
+// fetched. It returns which of the two it got.
+func (s *Scanner) fetchAvatar(ctx context.Context, jid types.JID) (*types.ProfilePictureInfo, string) {
+	client := s.Client
+	if s.Config.FullAvatar {
+		pic, err := client.GetProfilePictureInfo(ctx, jid, &whatsmeow.GetProfilePictureParams{})
+		if err == nil && pic != nil {
+			return pic, "full"
+		}
+		if errors.Is(err, whatsmeow.ErrProfilePictureNotSet) || errors.Is(err, whatsmeow.ErrProfilePictureUnauthorized) {
+			return nil, ""
+		}
+	}
+	pic, err := client.GetProfilePictureInfo(ctx, jid, &whatsmeow.GetProfilePictureParams{Preview: true})
+	if err != nil || pic == nil {
+		return nil, ""
+	}
+	return pic, "preview"
+}
+
+// accountType classifies an account as "business" when it has a business
+// profile or a verified name, and "personal" otherwise.
+func accountType(biz *types.BusinessProfile, verified string) string {
+	if biz != nil || verified != "" {
+		return "business"
+	}
+	return "personal"
+}
+
+func verifiedName(resp types.IsOnWhatsAppResponse) string {
+	if resp.VerifiedName != nil && resp.VerifiedName.Details != nil && resp.VerifiedName.Details.VerifiedName != nil {
+		return *resp.VerifiedName.Details.VerifiedName
+	}
+	return ""
+}
+
+// verifiedCert returns the issuer and serial of the business's verified
+// name certificate, if it has one.
+func verifiedCert(resp types.IsOnWhatsAppResponse) (issuer string, serial uint64) {
+	if resp.VerifiedName == nil || resp.VerifiedName.Details == nil {
+		return "", 0
+	}
+	details := resp.VerifiedName.Details
+	if details.Issuer != nil {
+		issuer = *details.Issuer
+	}
+	if details.Serial != nil {
+		serial = *details.Serial
+	}
+	return issuer, serial
+}
+
+// jitterDelay returns a random extra wait in [0, limit) so workers don't
+// settle into a fixed cadence. math/rand/v2 is seeded from the OS per run.
+func jitterDelay(limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit)
+}
+
+// isOnWhatsAppWithRetry also returns the round-trip time of the last
+// attempt, leaving out earlier attempts and the backoff between them.
+func (s *Scanner) isOnWhatsAppWithRetry(ctx context.Context, pns []string) ([]types.IsOnWhatsAppResponse, time.Duration, error) {
+	backoff := s.Config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, err := s.Client.IsOnWhatsApp(context.WithoutCancel(ctx), pns)
+		rtt := time.Since(start)
+		if err == nil || attempt > s.Config.Retries || !isTransientError(err) {
+			return resp, rtt, err
+		}
+		if s.Config.Verbose {
+			slog.Info("Check failed, retrying", "event", "retry", "phone", strings.Join(pns, ","), "attempt", attempt, "max_attempts", s.Config.Retries+1, "backoff", backoff.String(), "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-time.After(backoff):
+		}
+		// If the connection dropped, hold the retry until it is back.
+		if err := s.waitConnected(ctx); err != nil {
+			return nil, 0, err
+		}
+		backoff *= 2
+	}
+}
+
+func nextBatch(jids <-chan string, size int) []string {
+	var batch []string
+	for jid := range jids {
+		batch = append(batch, jid)
+		if len(batch) >= size {
+			break
+		}
+	}
+	return batch
+}
+
+func isTransientError(err error) bool {
+	var netErr net.Error
+	var discErr *whatsmeow.DisconnectedError
+	switch {
+	case errors.Is(err, whatsmeow.ErrIQTimedOut),
+		errors.Is(err, whatsmeow.ErrNotConnected),
+		errors.Is(err, whatsmeow.ErrIQRateOverLimit),
+		errors.Is(err, whatsmeow.ErrIQInternalServerError),
+		errors.Is(err, whatsmeow.ErrIQServiceUnavailable),
+		errors.Is(err, whatsmeow.ErrIQPartialServerError),
+		errors.As(err, &discErr),
+		errors.As(err, &netErr):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,955 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waVnameCert"
+	"go.mau.fi/whatsmeow/types"
+	"golang.org/x/time/rate"
+)
+
+func scanPhones(t *testing.T, s *Scanner, patterns ...string) []string {
+	t.Helper()
+	results, err := s.Run(context.Background(), patterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var phones []string
+	for _, res := range results {
+		phones = append(phones, res.Phone)
+	}
+	sort.Strings(phones)
+	return phones
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScannerRunFindsRegistered(t *testing.T) {
+	client := newFakeClient("15550000003", "15550000007")
+	client.status["15550000003"] = "Hey there"
+	s := &Scanner{Client: client, Concurrency: 3, BatchSize: 2}
+
+	results, err := s.Run(context.Background(), []string{"1555000000x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for _, res := range results {
+		if !res.Found || res.Link != "https://wa.me/"+res.Phone {
+			t.Errorf("unexpected result %+v", res)
+		}
+		if res.Phone == "15550000003" && res.Status != "Hey there" {
+			t.Errorf("status = %q", res.Status)
+		}
+	}
+	checked := 0
+	for _, req := range client.requests {
+		if len(req) > 2 {
+			t.Errorf("batch of %d numbers with BatchSize 2", len(req))
+		}
+		checked += len(req)
+	}
+	if checked != 10 {
+		t.Errorf("checked %d numbers, want 10", checked)
+	}
+}
+
+func TestScannerIncludeNotFound(t *testing.T) {
+	client := newFakeClient("15550000003")
+	s := &Scanner{Client: client, Config: ScanConfig{NotFound: true}}
+	results, err := s.Run(context.Background(), []string{"1555000000x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, res := range results {
+		if res.Found {
+			found++
+		} else if res.Link != "" {
+			t.Errorf("not-found result has a link: %+v", res)
+		}
+	}
+	if len(results) != 10 || found != 1 {
+		t.Errorf("got %d results (%d found), want 10 (1 found)", len(results), found)
+	}
+}
+
+func TestScannerSkip(t *testing.T) {
+	client := newFakeClient("15550000003", "15550000007")
+	s := &Scanner{Client: client, Skip: map[string]bool{"15550000003@c.us": true}}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000007"}) {
+		t.Errorf("got %v", got)
+	}
+	if got := client.callCount(); got != 9 {
+		t.Errorf("checked %d numbers, want 9", got)
+	}
+}
+
+func TestScannerDedupesPatterns(t *testing.T) {
+	client := newFakeClient("15550000003")
+	s := &Scanner{Client: client}
+	if got := scanPhones(t, s, "1555000000x", "15550000003"); !equalStrings(got, []string{"15550000003"}) {
+		t.Errorf("got %v", got)
+	}
+	if got := client.callCount(); got != 10 {
+		t.Errorf("checked %d numbers, want 10", got)
+	}
+}
+
+func TestScannerAccountType(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	client.business["15550000001"] = &types.BusinessProfile{Email: "a@b.c"}
+	client.verified["15550000002"] = "Verified Co"
+	results, err := (&Scanner{Client: client}).Run(context.Background(), []string{"1555000000[1-3]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"15550000001": "business",
+		"15550000002": "business",
+		"15550000003": "personal",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	col := slices.Index(csvHeader, "Type")
+	for _, res := range results {
+		if res.Type != want[res.Phone] {
+			t.Errorf("%s: type %q, want %q", res.Phone, res.Type, want[res.Phone])
+		}
+		if got := csvRow(res)[col]; got != res.Type {
+			t.Errorf("%s: CSV Type column %q", res.Phone, got)
+		}
+		data, _ := json.Marshal(res)
+		if !strings.Contains(string(data), `"type":"`+res.Type+`"`) {
+			t.Errorf("%s: JSON %s has no type", res.Phone, data)
+		}
+		if vcard := formatVCard(res); !strings.Contains(vcard, "CATEGORIES:"+res.Type+"\n") {
+			t.Errorf("%s: vCard has no category:\n%s", res.Phone, vcard)
+		}
+	}
+}
+
+func TestScannerNoEnrich(t *testing.T) {
+	client := newFakeClient("15550000003", "15550000004")
+	client.verified["15550000004"] = "Acme"
+	client.status["15550000003"] = "not fetched"
+	s := &Scanner{Client: client, Config: ScanConfig{NoEnrich: true}}
+	results, err := s.Run(context.Background(), []string{"1555000000[3-4]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if res.Status != "" {
+			t.Errorf("status fetched with NoEnrich: %+v", res)
+		}
+		// Without the business profile an account can only be told to be
+		// a business by its verified name.
+		want := ""
+		if res.Phone == "15550000004" {
+			want = "business"
+		}
+		if res.Type != want {
+			t.Errorf("%s: type %q, want %q", res.Phone, res.Type, want)
+		}
+	}
+	if got := client.enrichCount(); got != 0 {
+		t.Errorf("%d enrichment calls with NoEnrich, want none", got)
+	}
+
+	(&Scanner{Client: client}).Run(context.Background(), []string{"1555000000[3-4]"})
+	if client.enrichCount() == 0 {
+		t.Error("the fake counted no enrichment calls without NoEnrich")
+	}
+}
+
+func TestScannerFilters(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	client.business["15550000001"] = &types.BusinessProfile{Email: "a@b.c"}
+	client.verified["15550000002"] = "Verified Co"
+	client.pictures["15550000003"] = &types.ProfilePictureInfo{URL: "https://pps.example/3.jpg", ID: "3"}
+	client.status["15550000003"] = "at the gym"
+
+	tests := []struct {
+		name string
+		cfg  ScanConfig
+		want []string
+	}{
+		{"all", ScanConfig{}, []string{"15550000001", "15550000002", "15550000003"}},
+		{"only business", ScanConfig{OnlyBusiness: true}, []string{"15550000001", "15550000002"}},
+		{"verified only", ScanConfig{VerifiedOnly: true}, []string{"15550000002"}},
+		{"has avatar", ScanConfig{HasAvatar: true}, []string{"15550000003"}},
+		{"status match", ScanConfig{StatusMatch: regexp.MustCompile("gym")}, []string{"15550000003"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scanner{Client: client, Config: tt.cfg}
+			if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerMaxFound(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003", "15550000004")
+	s := &Scanner{Client: client, MaxFound: 2}
+	results, err := s.Run(context.Background(), []string{"1555000000x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !s.ReachedMax() {
+		t.Errorf("got %d results, ReachedMax %v", len(results), s.ReachedMax())
+	}
+}
+
+func TestScannerMaxFoundCountsUniqueAccounts(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	client.aliases["15550000002"] = "15550000001"
+	var dupes []string
+	s := &Scanner{
+		Client:      client,
+		MaxFound:    2,
+		OnDuplicate: func(res ScanResult) { dupes = append(dupes, res.Phone) },
+	}
+	got := scanPhones(t, s, "1555000000x")
+	if !equalStrings(got, []string{"15550000001", "15550000003"}) {
+		t.Errorf("got %v, want two distinct accounts", got)
+	}
+	if !equalStrings(dupes, []string{"15550000002"}) {
+		t.Errorf("OnDuplicate got %v", dupes)
+	}
+}
+
+func TestScannerRetriesTransientErrors(t *testing.T) {
+	client := newFakeClient("15550000001")
+	client.checkErrs = []error{whatsmeow.ErrIQTimedOut, whatsmeow.ErrIQRateOverLimit}
+	s := &Scanner{Client: client, Config: ScanConfig{Retries: 2, RetryBackoff: time.Millisecond}}
+	if got := scanPhones(t, s, "15550000001"); !equalStrings(got, []string{"15550000001"}) {
+		t.Errorf("got %v", got)
+	}
+	if got := client.callCount(); got != 3 {
+		t.Errorf("IsOnWhatsApp called %d times, want 3", got)
+	}
+}
+
+func TestScannerLatencyLeavesOutBackoff(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002")
+	client.delay = 5 * time.Millisecond
+	client.checkErrs = []error{whatsmeow.ErrIQTimedOut}
+	var samples []time.Duration
+	s := &Scanner{
+		Client:    client,
+		BatchSize: 2,
+		Config:    ScanConfig{Retries: 1, RetryBackoff: 100 * time.Millisecond},
+		OnChecked: func(batch []string, elapsed time.Duration) { samples = append(samples, elapsed) },
+	}
+	results, err := s.Run(context.Background(), []string{"1555000000[1-2]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("got %d samples for one batch", len(samples))
+	}
+	if samples[0] < client.delay || samples[0] >= s.Config.RetryBackoff {
+		t.Errorf("elapsed %s should be one round trip, without the backoff", samples[0])
+	}
+	for _, res := range results {
+		if res.Duration != samples[0] {
+			t.Errorf("%s: Duration %s, want %s", res.Phone, res.Duration, samples[0])
+		}
+	}
+}
+
+func TestScannerGivesUpAfterRetries(t *testing.T) {
+	client := newFakeClient("15550000001")
+	client.checkErrs = []error{whatsmeow.ErrIQTimedOut, whatsmeow.ErrIQTimedOut, whatsmeow.ErrIQTimedOut}
+	var failed []string
+	s := &Scanner{
+		Client:   client,
+		Config:   ScanConfig{Retries: 1, RetryBackoff: time.Millisecond},
+		OnFailed: func(pn string, err error) { failed = append(failed, pn) },
+	}
+	if got := scanPhones(t, s, "15550000001"); len(got) != 0 {
+		t.Errorf("got %v", got)
+	}
+	if got := client.callCount(); got != 2 {
+		t.Errorf("IsOnWhatsApp called %d times, want 2", got)
+	}
+	if !equalStrings(failed, []string{"15550000001"}) {
+		t.Errorf("OnFailed got %v", failed)
+	}
+}
+
+func TestScannerDoesNotRetryPermanentErrors(t *testing.T) {
+	client := newFakeClient("15550000001")
+	client.checkErrs = []error{errors.New("bad request")}
+	s := &Scanner{Client: client, Config: ScanConfig{Retries: 3, RetryBackoff: time.Millisecond}}
+	scanPhones(t, s, "15550000001")
+	if got := client.callCount(); got != 1 {
+		t.Errorf("IsOnWhatsApp called %d times, want 1", got)
+	}
+}
+
+func TestScannerBatchFallback(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000003")
+	client.batchErr = errors.New("batch rejected")
+	client.numberErrs["15550000002"] = errors.New("bad number")
+	var failed []string
+	s := &Scanner{
+		Client:    client,
+		BatchSize: 3,
+		OnFailed:  func(pn string, err error) { failed = append(failed, pn) },
+	}
+	if got := scanPhones(t, s, "1555000000[1-3]"); !equalStrings(got, []string{"15550000001", "15550000003"}) {
+		t.Errorf("got %v", got)
+	}
+	if !equalStrings(failed, []string{"15550000002"}) {
+		t.Errorf("OnFailed got %v", failed)
+	}
+}
+
+func TestScannerOnChecked(t *testing.T) {
+	client := newFakeClient()
+	checked := 0
+	s := &Scanner{
+		Client:    client,
+		BatchSize: 4,
+		OnChecked: func(batch []string, elapsed time.Duration) { checked += len(batch) },
+	}
+	scanPhones(t, s, "1555000000x")
+	if checked != 10 {
+		t.Errorf("OnChecked saw %d numbers, want 10", checked)
+	}
+}
+
+func TestScannerPatternOptions(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000011")
+	s := &Scanner{
+		Client:      client,
+		Concurrency: 8,
+		Options:     []PatternOptions{{Concurrency: 1}},
+	}
+	if phases := s.phases([]string{"1555000000x", "1555000001x"}); len(phases) != 2 {
+		t.Fatalf("got %d phases, want 2", len(phases))
+	}
+	if got := scanPhones(t, s, "1555000000x", "1555000001x"); !equalStrings(got, []string{"15550000001", "15550000011"}) {
+		t.Errorf("got %v", got)
+	}
+
+	client = newFakeClient()
+	s.Client = client
+	scanPhones(t, s, "1555000000x")
+	if client.peak != 1 {
+		t.Errorf("peak concurrency %d with concurrency=1", client.peak)
+	}
+}
+
+func TestScannerDuplicatePatternOptions(t *testing.T) {
+	// The same pattern on two lines: each line keeps its own options, and
+	// the numbers are checked once, in the first line's phase.
+	client := newFakeClient("15550000001")
+	client.delay = 5 * time.Millisecond
+	s := &Scanner{
+		Client:      client,
+		Concurrency: 8,
+		Options:     []PatternOptions{{Concurrency: 1}, {Concurrency: 8}},
+	}
+	patterns := []string{"1555000000x", "1555000000x"}
+	if phases := s.phases(patterns); len(phases) != 2 || phases[0].opts.Concurrency != 1 || phases[1].opts.Concurrency != 8 {
+		t.Fatalf("phases %+v, want concurrency 1 then 8", phases)
+	}
+	if got := scanPhones(t, s, patterns...); !equalStrings(got, []string{"15550000001"}) {
+		t.Errorf("got %v", got)
+	}
+	if client.callCount() != 10 || client.peak != 1 {
+		t.Errorf("%d calls at peak concurrency %d, want 10 at 1", client.callCount(), client.peak)
+	}
+}
+
+func TestScannerCancel(t *testing.T) {
+	client := newFakeClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := &Scanner{Client: client}
+	if _, err := s.Run(ctx, []string{"1555000xxxx"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestScannerRateLimit(t *testing.T) {
+	const perSecond = 100
+	client := newFakeClient()
+	s := &Scanner{Client: client, Concurrency: 4, Limiter: rate.NewLimiter(perSecond, 1)}
+	start := time.Now()
+	scanPhones(t, s, "155500000[0-2]x")
+	elapsed := time.Since(start)
+
+	// The burst of 1 lets the first request through at once.
+	n := client.callCount()
+	if want := time.Duration(n-1) * time.Second / perSecond; elapsed < want {
+		t.Errorf("%d requests took %s, want at least %s with %d workers", n, elapsed, want, s.Concurrency)
+	}
+}
+
+func TestIsOnWhatsAppWithRetryBacksOff(t *testing.T) {
+	client := newFakeClient("15550000001")
+	client.checkErrs = []error{whatsmeow.ErrIQTimedOut, whatsmeow.ErrIQServiceUnavailable}
+	s := &Scanner{Client: client, Config: ScanConfig{Retries: 3, RetryBackoff: 10 * time.Millisecond}}
+
+	start := time.Now()
+	resp, _, err := s.isOnWhatsAppWithRetry(context.Background(), []string{"+15550000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp) != 1 || !resp[0].IsIn {
+		t.Errorf("got %+v", resp)
+	}
+	// 10ms, then doubled to 20ms.
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("two retries took %s, want at least 30ms of backoff", elapsed)
+	}
+	if got := client.callCount(); got != 3 {
+		t.Errorf("IsOnWhatsApp called %d times, want 3", got)
+	}
+}
+
+func TestJitterDelay(t *testing.T) {
+	for _, limit := range []time.Duration{0, -time.Second} {
+		if got := jitterDelay(limit); got != 0 {
+			t.Errorf("jitterDelay(%s) = %s, want a fixed cadence", limit, got)
+		}
+	}
+	const limit = 100 * time.Millisecond
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1000; i++ {
+		d := jitterDelay(limit)
+		if d < 0 || d >= limit {
+			t.Fatalf("jitterDelay(%s) = %s, out of [0, %s)", limit, d, limit)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 100 {
+		t.Errorf("only %d distinct delays in 1000 draws", len(seen))
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{whatsmeow.ErrIQTimedOut, true},
+		{whatsmeow.ErrNotConnected, true},
+		{whatsmeow.ErrIQRateOverLimit, true},
+		{whatsmeow.ErrIQInternalServerError, true},
+		{&net.OpError{Op: "read", Err: errors.New("connection reset")}, true},
+		{fmt.Errorf("wrapped: %w", whatsmeow.ErrIQTimedOut), true},
+		{whatsmeow.ErrIQBadRequest, false},
+		{errors.New("bad request"), false},
+	}
+	for _, tt := range tests {
+		if got := isTransientError(tt.err); got != tt.want {
+			t.Errorf("isTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestScannerCancelMidScanDrainsResults(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002")
+	client.status["15550000001"] = "first"
+	client.delay = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scanner{Client: client, OnChecked: func(batch []string, elapsed time.Duration) {
+		if batch[0] == "15550000001@c.us" {
+			cancel()
+		}
+	}}
+
+	results, err := s.Run(ctx, []string{"155500000xx"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	// The number found before the cancel is still enriched and returned.
+	if len(results) != 1 || results[0].Phone != "15550000001" || results[0].Status != "first" {
+		t.Errorf("got %+v", results)
+	}
+	if got := client.callCount(); got > 3 {
+		t.Errorf("%d checks ran after cancelling", got)
+	}
+}
+
+func TestScannerPausesWhileDisconnected(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000005")
+	gate := NewConnGate()
+	var dropped sync.Once
+	reconnected := make(chan struct{})
+	var checkedWhileDown []string
+	client.checkErrs = []error{whatsmeow.ErrNotConnected}
+	client.onCall = func(phones []string) {
+		// The first check finds the connection gone, as events.Disconnected
+		// would, and it comes back 50ms later.
+		first := false
+		dropped.Do(func() {
+			first = true
+			gate.Down()
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				close(reconnected)
+				gate.Up()
+			}()
+		})
+		select {
+		case <-reconnected:
+		default:
+			if !first {
+				checkedWhileDown = append(checkedWhileDown, phones...)
+			}
+		}
+	}
+	var failed []string
+	s := &Scanner{
+		Client:   client,
+		Gate:     gate,
+		Config:   ScanConfig{Retries: 1, RetryBackoff: time.Millisecond},
+		OnFailed: func(pn string, err error) { failed = append(failed, pn) },
+	}
+
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000001", "15550000005"}) {
+		t.Errorf("got %v", got)
+	}
+	if len(checkedWhileDown) > 0 {
+		t.Errorf("checked %v while the connection was down", checkedWhileDown)
+	}
+	if len(failed) > 0 {
+		t.Errorf("%v failed instead of being retried after reconnecting", failed)
+	}
+	// Every number once, plus the retry of the one that hit the disconnect.
+	if got := client.callCount(); got != 11 {
+		t.Errorf("IsOnWhatsApp called %d times, want 11", got)
+	}
+}
+
+func TestScannerTimeout(t *testing.T) {
+	client := newFakeClient("15550000000")
+	client.delay = 10 * time.Millisecond
+	// -timeout is a deadline on the scan context.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results, err := (&Scanner{Client: client}).Run(ctx, []string{"155500000xx"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("scan took %s after a 50ms timeout", elapsed)
+	}
+	if got := client.callCount(); got >= 100 {
+		t.Errorf("all %d numbers were checked despite the timeout", got)
+	}
+	if len(results) != 1 || results[0].Phone != "15550000000" {
+		t.Errorf("results found before the timeout: got %+v", results)
+	}
+}
+
+func TestScannerBatches(t *testing.T) {
+	client := newFakeClient("15550000002", "15550000009")
+	s := &Scanner{Client: client, BatchSize: 4}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000002", "15550000009"}) {
+		t.Errorf("got %v", got)
+	}
+	var sizes []int
+	for _, req := range client.requests {
+		sizes = append(sizes, len(req))
+	}
+	if len(sizes) != 3 || sizes[0] != 4 || sizes[1] != 4 || sizes[2] != 2 {
+		t.Errorf("request sizes %v, want [4 4 2]", sizes)
+	}
+}
+
+func TestScannerStagedPipeline(t *testing.T) {
+	var numbers []string
+	for i := 0; i < 20; i++ {
+		numbers = append(numbers, fmt.Sprintf("155500000%02d", i))
+	}
+	client := newFakeClient(numbers...)
+	client.status["15550000007"] = "seven"
+	client.enrichDelay = 20 * time.Millisecond
+	s := &Scanner{Client: client, EnrichWorkers: 4}
+
+	start := time.Now()
+	var phones []string
+	checkedBeforeFirst := -1
+	for res := range s.Start(context.Background(), []string{"155500000[01]x"}) {
+		if checkedBeforeFirst < 0 {
+			checkedBeforeFirst = client.callCount()
+		}
+		if res.Phone == "15550000007" && res.Status != "seven" {
+			t.Errorf("%s wasn't enriched: %+v", res.Phone, res)
+		}
+		phones = append(phones, res.Phone)
+	}
+	elapsed := time.Since(start)
+
+	sort.Strings(phones)
+	if !equalStrings(phones, numbers) {
+		t.Errorf("got %v", phones)
+	}
+	// Existence checks don't wait for the slow business lookups.
+	if checkedBeforeFirst != 20 {
+		t.Errorf("only %d numbers checked by the time the first was enriched", checkedBeforeFirst)
+	}
+	if serial := 20 * client.enrichDelay; elapsed >= serial*3/4 {
+		t.Errorf("enrichment took %s, want the 4 workers well under the serial %s", elapsed, serial)
+	}
+}
+
+func TestScannerStatusMatch(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003", "15550000004")
+	client.status["15550000001"] = "Orders: https://shop.example"
+	client.status["15550000002"] = "Hey there! I am using WhatsApp."
+	client.status["15550000003"] = "SHOP open 9-5"
+	s := &Scanner{Client: client, Config: ScanConfig{StatusMatch: regexp.MustCompile(`(?i)shop`)}}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000001", "15550000003"}) {
+		t.Errorf("got %v", got)
+	}
+	// Accounts without a status are matched against the empty string.
+	s = &Scanner{Client: client, Config: ScanConfig{StatusMatch: regexp.MustCompile(`^$`)}}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000004"}) {
+		t.Errorf("^$: got %v", got)
+	}
+
+	if code := runArgs(t, "-dry-run", "-status-match", "(shop", "15550000001"); code != exitUsage {
+		t.Errorf("invalid -status-match: exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestScannerOnlyBusiness(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	client.business["15550000001"] = &types.BusinessProfile{Email: "shop@example.com"}
+	client.business["15550000003"] = &types.BusinessProfile{Address: "1 Main St"}
+	s := &Scanner{Client: client, Config: ScanConfig{OnlyBusiness: true}}
+	results, err := s.Run(context.Background(), []string{"1555000000x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want the 2 business accounts", len(results))
+	}
+	for _, res := range results {
+		if res.Business == nil || res.Type != "business" {
+			t.Errorf("%s: business %v, type %q", res.Phone, res.Business, res.Type)
+		}
+	}
+}
+
+func TestScannerVerifiedOnly(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003", "15550000004")
+	client.verified["15550000001"] = "Acme"
+	client.verified["15550000003"] = "Globex"
+	client.business["15550000001"] = &types.BusinessProfile{Email: "shop@acme.example"}
+	client.business["15550000002"] = &types.BusinessProfile{Email: "shop@initech.example"}
+
+	results, err := (&Scanner{Client: client, Config: ScanConfig{VerifiedOnly: true}}).Run(context.Background(), []string{"1555000000x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string)
+	for _, res := range results {
+		names[res.Phone] = res.VerifiedName
+	}
+	if len(names) != 2 || names["15550000001"] != "Acme" || names["15550000003"] != "Globex" {
+		t.Errorf("-verified-only: got %v", names)
+	}
+
+	// A verified name makes an account a business one, so together the
+	// filters drop the unverified business as well as personal accounts.
+	s := &Scanner{Client: client, Config: ScanConfig{OnlyBusiness: true}}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000001", "15550000002", "15550000003"}) {
+		t.Errorf("-only-business: got %v", got)
+	}
+	s = &Scanner{Client: client, Config: ScanConfig{VerifiedOnly: true, OnlyBusiness: true}}
+	if got := scanPhones(t, s, "1555000000x"); !equalStrings(got, []string{"15550000001", "15550000003"}) {
+		t.Errorf("-verified-only with -only-business: got %v", got)
+	}
+}
+
+func TestScannerPushName(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	client.contacts["15550000001"] = types.ContactInfo{Found: true, FullName: "Alice Smith", PushName: "Ally"}
+	client.contacts["15550000002"] = types.ContactInfo{Found: true, PushName: "Bob"}
+	results, err := (&Scanner{Client: client}).Run(context.Background(), []string{"1555000000[1-3]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][2]string)
+	for _, res := range results {
+		got[res.Phone] = [2]string{res.Name, res.PushName}
+	}
+	want := map[string][2]string{
+		"15550000001": {"Alice Smith", "Ally"},
+		"15550000002": {"Bob", "Bob"},
+		"15550000003": {"", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("[Name PushName]: got %v, want %v", got, want)
+	}
+
+	res := ScanResult{Phone: "15550000001", Name: "Alice Smith", PushName: "Ally"}
+	row := csvRow(res)
+	if row[slices.Index(csvHeader, "Name")] != "Alice Smith" || row[slices.Index(csvHeader, "PushName")] != "Ally" {
+		t.Errorf("CSV row %v", row)
+	}
+	data, _ := json.Marshal(res)
+	if !strings.Contains(string(data), `"name":"Alice Smith"`) || !strings.Contains(string(data), `"push_name":"Ally"`) {
+		t.Errorf("JSON %s", data)
+	}
+}
+
+func TestScannerFullAvatar(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	for _, pn := range []string{"15550000001", "15550000002"} {
+		client.pictures[pn] = &types.ProfilePictureInfo{URL: "https://pps.example/full/" + pn, ID: "1"}
+		client.previews[pn] = &types.ProfilePictureInfo{URL: "https://pps.example/preview/" + pn, ID: "1"}
+	}
+	client.fullErrs["15550000002"] = whatsmeow.ErrIQTimedOut
+
+	tests := []struct {
+		full bool
+		want map[string]string
+	}{
+		{true, map[string]string{
+			"15550000001": "full https://pps.example/full/15550000001",
+			// The full picture failed, so the preview is used instead.
+			"15550000002": "preview https://pps.example/preview/15550000002",
+			"15550000003": " ",
+		}},
+		{false, map[string]string{
+			"15550000001": "preview https://pps.example/preview/15550000001",
+			"15550000002": "preview https://pps.example/preview/15550000002",
+			"15550000003": " ",
+		}},
+	}
+	for _, tt := range tests {
+		results, err := (&Scanner{Client: client, Config: ScanConfig{FullAvatar: tt.full}}).Run(context.Background(), []string{"1555000000[1-3]"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		for _, res := range results {
+			got[res.Phone] = res.AvatarSize + " " + res.AvatarURL
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FullAvatar=%v: got %v, want %v", tt.full, got, tt.want)
+		}
+	}
+}
+
+func TestScannerVerifiedCert(t *testing.T) {
+	name, issuer, serial := "Acme", "smb", uint64(7364183901)
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	client.certs["15550000001"] = &types.VerifiedName{Details: &waVnameCert.VerifiedNameCertificate_Details{
+		VerifiedName: &name, Issuer: &issuer, Serial: &serial,
+	}}
+	// Missing sub-fields are left empty rather than failing.
+	client.certs["15550000002"] = &types.VerifiedName{Details: &waVnameCert.VerifiedNameCertificate_Details{VerifiedName: &name}}
+	client.certs["15550000003"] = &types.VerifiedName{}
+
+	results, err := (&Scanner{Client: client}).Run(context.Background(), []string{"1555000000[1-3]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, res := range results {
+		got[res.Phone] = fmt.Sprintf("%s/%s/%d", res.VerifiedName, res.CertIssuer, res.CertSerial)
+	}
+	want := map[string]string{
+		"15550000001": "Acme/smb/7364183901",
+		"15550000002": "Acme//0",
+		"15550000003": "//0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	res := ScanResult{Phone: "15550000001", VerifiedName: name, CertIssuer: issuer, CertSerial: serial}
+	row := csvRow(res)
+	if row[slices.Index(csvHeader, "VerifiedIssuer")] != "smb" || row[slices.Index(csvHeader, "VerifiedSerial")] != "7364183901" {
+		t.Errorf("CSV row %v", row)
+	}
+	data, _ := json.Marshal(res)
+	if !strings.Contains(string(data), `"verified_issuer":"smb","verified_serial":7364183901`) {
+		t.Errorf("JSON %s", data)
+	}
+}
+
+func TestScannerLID(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002")
+	client.lids["15550000001"] = "123456789012345"
+	results, err := (&Scanner{Client: client}).Run(context.Background(), []string{"1555000000[12]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lids := make(map[string]string)
+	for _, res := range results {
+		lids[res.Phone] = res.LID
+	}
+	if lids["15550000001"] != "123456789012345@lid" || lids["15550000002"] != "" {
+		t.Errorf("got %v", lids)
+	}
+	row := csvRow(ScanResult{Phone: "15550000001", LID: "123456789012345@lid"})
+	if i := slices.Index(csvHeader, "LID"); row[i] != "123456789012345@lid" {
+		t.Errorf("CSV LID column = %q", row[i])
+	}
+}
+
+func TestScannerHasAvatar(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002", "15550000003")
+	client.pictures["15550000002"] = &types.ProfilePictureInfo{URL: "https://pps.example/2.jpg", ID: "2"}
+	s := &Scanner{Client: client, Config: ScanConfig{HasAvatar: true}}
+	results, err := s.Run(context.Background(), []string{"1555000000x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Phone != "15550000002" || results[0].AvatarURL != "https://pps.example/2.jpg" {
+		t.Errorf("got %+v, want only the account with a picture", results)
+	}
+}
+
+func TestScannerCollapsesSameAccount(t *testing.T) {
+	// The same account reached with and without a trunk zero.
+	client := newFakeClient("4915112345678", "49015112345678")
+	client.aliases["49015112345678"] = "4915112345678"
+	dupes := 0
+	s := &Scanner{Client: client, OnDuplicate: func(ScanResult) { dupes++ }}
+	results, err := s.Run(context.Background(), []string{"4915112345678", "49015112345678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || dupes != 1 {
+		t.Fatalf("got %d results and %d duplicates, want 1 and 1", len(results), dupes)
+	}
+	if results[0].ResolvedJID != "4915112345678@s.whatsapp.net" {
+		t.Errorf("ResolvedJID = %q", results[0].ResolvedJID)
+	}
+}
+
+type fakeContacts map[types.JID]types.ContactInfo
+
+func (f fakeContacts) GetAllContacts(ctx context.Context) (map[types.JID]types.ContactInfo, error) {
+	return f, nil
+}
+
+func TestContactNumbers(t *testing.T) {
+	store := fakeContacts{
+		types.NewJID("15550000002", types.DefaultUserServer): {FullName: "Bob"},
+		types.NewJID("15550000001", types.DefaultUserServer): {FullName: "Alice"},
+		types.NewJID("123456789", types.GroupServer):         {},
+		types.NewJID("98765", types.HiddenUserServer):        {},
+		types.NewJID("status", types.DefaultUserServer):      {},
+	}
+	numbers, err := contactNumbers(context.Background(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(numbers, []string{"15550000001", "15550000002"}) {
+		t.Fatalf("got %v, want the two phone contacts in order", numbers)
+	}
+
+	client := newFakeClient("15550000002")
+	if got := scanPhones(t, &Scanner{Client: client}, numbers...); !equalStrings(got, []string{"15550000002"}) {
+		t.Errorf("scan of contacts found %v", got)
+	}
+}
+
+func TestScannerDevices(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002")
+	client.devices["15550000001"] = []types.JID{
+		{User: "15550000001", Device: 0, Server: types.DefaultUserServer},
+		{User: "15550000001", Device: 12, Server: types.DefaultUserServer},
+		{User: "15550000001", Device: 21, Server: types.DefaultUserServer},
+	}
+	results, err := (&Scanner{Client: client}).Run(context.Background(), []string{"1555000000[1-2]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"15550000001": {"15550000001@s.whatsapp.net", "15550000001:12@s.whatsapp.net", "15550000001:21@s.whatsapp.net"},
+		"15550000002": nil,
+	}
+	for _, res := range results {
+		if !equalStrings(res.Devices, want[res.Phone]) {
+			t.Errorf("%s: devices %v, want %v", res.Phone, res.Devices, want[res.Phone])
+		}
+	}
+}
+
+// TestScannerBoundedQueues stops reading results from a scan of a million
+// numbers that are all on WhatsApp and checks that the workers stall once
+// the queues are full instead of buffering the whole range.
+func TestScannerBoundedQueues(t *testing.T) {
+	client := newFakeClient()
+	client.registerAll = true
+	s := &Scanner{Client: client, Concurrency: 4, Config: ScanConfig{NoEnrich: true}}
+	ctx, cancel := context.WithCancel(context.Background())
+	results := s.Start(ctx, []string{"1555xxxxxx"})
+	defer func() {
+		cancel()
+		for range results {
+		}
+	}()
+
+	stalled := 0
+	for {
+		time.Sleep(50 * time.Millisecond)
+		n := client.callCount()
+		if n > 0 && n == stalled {
+			break
+		}
+		stalled = n
+	}
+	// The enrichment queue, the result buffer, the JID queue and the
+	// numbers the workers hold.
+	limit := enrichQueueSize + resultsPerWorker + 4 + 2*4
+	if stalled > limit {
+		t.Errorf("checked %d numbers with nobody reading results, want at most %d", stalled, limit)
+	}
+}
+
+// BenchmarkScannerLargePattern scans 100k numbers through the fake; run it
+// with -benchmem to watch the allocations per scan.
+func BenchmarkScannerLargePattern(b *testing.B) {
+	client := newFakeClient()
+	client.registerAll = true
+	for i := 0; i < b.N; i++ {
+		s := &Scanner{Client: client, Concurrency: 4, BatchSize: 50, Config: ScanConfig{NoEnrich: true}}
+		for range s.Start(context.Background(), []string{"1555xxxxx"}) {
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// scanFunc runs one scan over patterns, calling emit for every found account.
+// configure, if not nil, adjusts the scanner before it starts.
+type scanFunc func(ctx context.Context, patterns []string, configure func(*Scanner), emit func(ScanResult)) error
+
+type scanRequest struct {
+	Pattern  string   `json:"pattern"`
+	Patterns []string `json:"patterns"`
+	Stream   bool     `json:"stream"`
+	scanOptions
+}
+
+// scanOptions are the per-request overrides a POST /scan body can carry.
+// Unset fields keep the server's flags.
+type scanOptions struct {
+	Delay        string `json:"delay"`
+	Jitter       string `json:"jitter"`
+	Concurrency  int    `json:"concurrency"`
+	NotFound     *bool  `json:"include_not_found"`
+	VerifiedOnly *bool  `json:"verified_only"`
+	OnlyBusiness *bool  `json:"only_business"`
+	HasAvatar    *bool  `json:"has_avatar"`
+	NoEnrich     *bool  `json:"no_enrich"`
+	Validate     *bool  `json:"validate"`
+	Sane         *bool  `json:"sane"`
+	StatusMatch  string `json:"status_match"`
+	MaxFound     int    `json:"max_found"`
+}
+
+// configure validates the options and returns the function that applies
+// them to a request's scanner.
+func (o scanOptions) configure() (func(*Scanner), error) {
+	var pace PatternOptions
+	options := map[string]string{"delay": o.Delay, "jitter": o.Jitter}
+	if o.Concurrency != 0 {
+		options["concurrency"] = strconv.Itoa(o.Concurrency)
+	}
+	for key, value := range options {
+		if value == "" {
+			continue
+		}
+		if err := pace.set(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if o.MaxFound < 0 {
+		return nil, fmt.Errorf("invalid max_found %d", o.MaxFound)
+	}
+	var statusMatch *regexp.Regexp
+	if o.StatusMatch != "" {
+		var err error
+		if statusMatch, err = regexp.Compile(o.StatusMatch); err != nil {
+			return nil, fmt.Errorf("invalid status_match: %v", err)
+		}
+	}
+	noEnrich := o.NoEnrich != nil && *o.NoEnrich
+	if noEnrich && (isTrue(o.OnlyBusiness) || isTrue(o.HasAvatar) || statusMatch != nil) {
+		return nil, fmt.Errorf("no_enrich can't be combined with only_business, has_avatar or status_match")
+	}
+
+	return func(s *Scanner) {
+		cfg := &s.Config
+		p := pace.pacing(pacing{delay: cfg.Delay, jitter: cfg.Jitter})
+		cfg.Delay, cfg.Jitter = p.delay, p.jitter
+		if pace.Concurrency > 0 {
+			s.Concurrency = pace.Concurrency
+		}
+		for _, b := range []struct {
+			field *bool
+			value *bool
+		}{
+			{&cfg.NotFound, o.NotFound},
+			{&cfg.VerifiedOnly, o.VerifiedOnly},
+			{&cfg.OnlyBusiness, o.OnlyBusiness},
+			{&cfg.HasAvatar, o.HasAvatar},
+			{&cfg.NoEnrich, o.NoEnrich},
+			{&cfg.Validate, o.Validate},
+			{&cfg.Sane, o.Sane},
+		} {
+			if b.value != nil {
+				*b.field = *b.value
+			}
+		}
+		if statusMatch != nil {
+			cfg.StatusMatch = statusMatch
+		}
+		if noEnrich {
+			// Nothing is fetched to filter or save on.
+			cfg.OnlyBusiness, cfg.HasAvatar, cfg.StatusMatch = false, false, nil
+			cfg.AvatarDir, cfg.FollowAvatar = "", false
+		}
+		if o.MaxFound > 0 {
+			s.MaxFound = o.MaxFound
+		}
+	}, nil
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// ScanServer is the -serve HTTP API. Scans run one at a time on the shared
+// session so concurrent requests queue instead of multiplying the request
+// rate seen by WhatsApp.
+type ScanServer struct {
+	scan    scanFunc
+	healthy func() bool
+	limit   int
+	mu      sync.Mutex
+}
+
+func NewScanServer(scan scanFunc, healthy func() bool, limit int) *ScanServer {
+	return &ScanServer{scan: scan, healthy: healthy, limit: limit}
+}
+
+func (s *ScanServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan", s.handleScan)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	return mux
+}
+
+func (s *ScanServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !s.healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "disconnected"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func (s *ScanServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var req scanRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	patterns, err := requestPatterns(req, s.limit)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	configure, err := req.configure()
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	stream := req.Stream || r.Header.Get("Accept") == "application/x-ndjson"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Context().Err() != nil {
+		return
+	}
+
+	if stream {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		flusher, _ := w.(http.Flusher)
+		err = s.scan(r.Context(), patterns, configure, func(res ScanResult) {
+			enc.Encode(res)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+		if err != nil && *verbose {
+			slog.Warn("Scan request failed", "event", "serve", "error", err)
+		}
+		return
+	}
+
+	results := []ScanResult{}
+	err = s.scan(r.Context(), patterns, configure, func(res ScanResult) {
+		results = append(results, res)
+	})
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+func requestPatterns(req scanRequest, limit int) ([]string, error) {
+	raw := req.Patterns
+	if req.Pattern != "" {
+		raw = append([]string{req.Pattern}, raw...)
+	}
+	var patterns []string
+	for _, p := range raw {
+		pattern := normalizePattern(p)
+		if pattern == "" {
+			continue
+		}
+		if err := validatePattern(pattern); err != nil {
+			return nil, fmt.Errorf("invalid phone number pattern '%s'", p)
+		}
+		patterns = append(patterns, pattern)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no pattern provided")
+	}
+	if err := checkPatternSize(patterns, limit); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// clientScan scans patterns with the live session. Each request gets a fresh
+// Scanner from newScanner, so it runs through the same workers, rate limit
+// and connection gate as a command-line scan.
+func clientScan(newScanner func() *Scanner) scanFunc {
+	return func(ctx context.Context, patterns []string, configure func(*Scanner), emit func(ScanResult)) error {
+		scanner := newScanner()
+		if configure != nil {
+			configure(scanner)
+		}
+		for res := range scanner.Start(ctx, patterns) {
+			emit(res)
+		}
+		return ctx.Err()
+	}
+}
+
+// runServer serves the scan API on addr until ctx is cancelled.
+func runServer(ctx context.Context, addr string, server *ScanServer) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: server.Handler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func newTestServer(t *testing.T, newScanner func() *Scanner, healthy bool) *httptest.Server {
+	t.Helper()
+	server := NewScanServer(clientScan(newScanner), func() bool { return healthy }, 1000)
+	ts := httptest.NewServer(server.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func postScan(t *testing.T, ts *httptest.Server, body string) *http.Response {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/scan", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestServerScanJSON(t *testing.T) {
+	client := newFakeClient("15550000003", "15550000007")
+	ts := newTestServer(t, func() *Scanner { return &Scanner{Client: client, BatchSize: 3} }, true)
+
+	resp := postScan(t, ts, `{"pattern": "+1 555 000 000x"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var results []ScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	var phones []string
+	for _, res := range results {
+		phones = append(phones, res.Phone)
+	}
+	sort.Strings(phones)
+	if !equalStrings(phones, []string{"15550000003", "15550000007"}) {
+		t.Errorf("got %v", phones)
+	}
+}
+
+func TestServerScanStream(t *testing.T) {
+	client := newFakeClient("15550000001", "15550000002")
+	ts := newTestServer(t, func() *Scanner { return &Scanner{Client: client} }, true)
+
+	resp := postScan(t, ts, `{"patterns": ["1555000000x"], "stream": true}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	lines := 0
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		var res ScanResult
+		if err := json.Unmarshal(sc.Bytes(), &res); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("got %d lines, want 2", lines)
+	}
+}
+
+func TestServerScanUsesScannerSettings(t *testing.T) {
+	client := newFakeClient()
+	client.delay = 5 * time.Millisecond
+	built := 0
+	ts := newTestServer(t, func() *Scanner {
+		built++
+		return &Scanner{Client: client, Concurrency: 4}
+	}, true)
+
+	for i := 0; i < 2; i++ {
+		postScan(t, ts, `{"pattern": "1555000000x"}`)
+	}
+	if built != 2 {
+		t.Errorf("built %d scanners for 2 requests", built)
+	}
+	if client.peak < 2 {
+		t.Errorf("peak concurrency %d, want the scanner's workers to run in parallel", client.peak)
+	}
+	if got := client.callCount(); got != 20 {
+		t.Errorf("checked %d numbers, want 20", got)
+	}
+}
+
+func TestServerScanOptions(t *testing.T) {
+	client := newFakeClient("15550000003", "15550000004")
+	client.business["15550000004"] = &types.BusinessProfile{}
+	var built *Scanner
+	ts := newTestServer(t, func() *Scanner {
+		built = &Scanner{Client: client, Concurrency: 8, Config: ScanConfig{Delay: 20 * time.Millisecond, Jitter: time.Millisecond}}
+		return built
+	}, true)
+
+	resp := postScan(t, ts, `{"pattern": "1555000000x", "delay": "1ms", "jitter": "0s", "concurrency": 2,
+		"include_not_found": true, "only_business": true, "status_match": "^$", "max_found": 5}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var results []ScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	cfg := built.Config
+	if cfg.Delay != time.Millisecond || cfg.Jitter != 0 || built.Concurrency != 2 || built.MaxFound != 5 {
+		t.Errorf("delay %s, jitter %s, concurrency %d, max found %d", cfg.Delay, cfg.Jitter, built.Concurrency, built.MaxFound)
+	}
+	if !cfg.NotFound || !cfg.OnlyBusiness || cfg.StatusMatch == nil || cfg.StatusMatch.String() != "^$" {
+		t.Errorf("filters not applied: %+v", cfg)
+	}
+	// 15550000003 isn't a business; the other eight aren't on WhatsApp and
+	// are reported with include_not_found.
+	found := 0
+	for _, res := range results {
+		if res.Phone == "15550000003" {
+			t.Errorf("only_business let through %+v", res)
+		}
+		if res.Found {
+			found++
+		}
+	}
+	if found != 1 || len(results) != 9 {
+		t.Errorf("got %d results (%d found), want 9 (1 found)", len(results), found)
+	}
+
+	// Options are per request: the next scan is back on the server's flags.
+	postScan(t, ts, `{"pattern": "1555000000x"}`)
+	if built.Config.Delay != 20*time.Millisecond || built.Concurrency != 8 || built.Config.OnlyBusiness {
+		t.Errorf("options leaked into the next request: %+v", built)
+	}
+}
+
+func TestServerRejectsBadRequests(t *testing.T) {
+	ts := newTestServer(t, func() *Scanner { return &Scanner{Client: newFakeClient()} }, true)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{`},
+		{"no pattern", `{}`},
+		{"bad pattern", `{"pattern": "15abc"}`},
+		{"too many numbers", `{"pattern": "1555xxxxxx"}`},
+		{"unknown field", `{"pattern": "1555000000x", "speed": "fast"}`},
+		{"bad delay", `{"pattern": "1555000000x", "delay": "soon"}`},
+		{"negative jitter", `{"pattern": "1555000000x", "jitter": "-1s"}`},
+		{"bad concurrency", `{"pattern": "1555000000x", "concurrency": -1}`},
+		{"bad max found", `{"pattern": "1555000000x", "max_found": -1}`},
+		{"bad status match", `{"pattern": "1555000000x", "status_match": "("}`},
+		{"no enrich with a filter", `{"pattern": "1555000000x", "no_enrich": true, "has_avatar": true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := postScan(t, ts, tt.body); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("status %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+
+	resp, err := http.Get(ts.URL + "/scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /scan: status %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	for _, healthy := range []bool{true, false} {
+		ts := newTestServer(t, func() *Scanner { return nil }, healthy)
+		resp, err := http.Get(ts.URL + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		want := http.StatusOK
+		if !healthy {
+			want = http.StatusServiceUnavailable
+		}
+		if resp.StatusCode != want {
+			t.Errorf("healthy=%v: status %d, want %d", healthy, resp.StatusCode, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+const resultsSchema = `CREATE TABLE IF NOT EXISTS results (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	phone         TEXT NOT NULL,
+	jid           TEXT NOT NULL,
+	link          TEXT,
+	status        TEXT,
+	name          TEXT,
+	verified_name TEXT,
+	email         TEXT,
+	address       TEXT,
+	avatar_url    TEXT,
+	avatar_path   TEXT,
+	found_at      TIMESTAMP NOT NULL
+)`
+
+const resultsBatchSize = 100
+
+type ResultDB struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	pending int
+}
+
+func OpenResultDB(path string) (*ResultDB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(resultsSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &ResultDB{db: db}, nil
+}
+
+// Insert queues a result in the current transaction, committing every
+// resultsBatchSize rows.
+func (r *ResultDB) Insert(res ScanResult) error {
+	if r.tx == nil {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare(`INSERT INTO results
+			(phone, jid, link, status, name, verified_name, email, address, avatar_url, avatar_path, found_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		r.tx, r.stmt = tx, stmt
+	}
+
+	email, address := "", ""
+	if res.Business != nil {
+		email = res.Business.Email
+		address = res.Business.Address
+	}
+	_, err := r.stmt.Exec(res.Phone, res.JID, res.Link, res.Status, res.Name, res.VerifiedName,
+		email, address, res.AvatarURL, res.AvatarPath, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	r.pending++
+	if r.pending >= resultsBatchSize {
+		return r.commit()
+	}
+	return nil
+}
+
+func (r *ResultDB) commit() error {
+	if r.tx == nil {
+		return nil
+	}
+	r.stmt.Close()
+	err := r.tx.Commit()
+	r.tx, r.stmt, r.pending = nil, nil, 0
+	return err
+}
+
+// Flush commits the rows inserted so far.
+func (r *ResultDB) Flush() error {
+	return r.commit()
+}
+
+func (r *ResultDB) Close() error {
+	err := r.commit()
+	if closeErr := r.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
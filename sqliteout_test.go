@@ -0,0 +1,42 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestResultDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	for run := 0; run < 2; run++ {
+		rdb, err := OpenResultDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rdb.Insert(ScanResult{Phone: "15550000001", JID: "15550000001@c.us", Status: "Hey there"})
+		rdb.Insert(ScanResult{Phone: "15550000002", JID: "15550000002@c.us", Business: &types.BusinessProfile{Email: "a@b.c", Address: "1 Main St"}})
+		if err := rdb.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM results").Scan(&n)
+	if n != 4 {
+		t.Errorf("got %d rows after two runs, want 4", n)
+	}
+	var status, email, address string
+	if err := db.QueryRow("SELECT status FROM results WHERE phone = '15550000001' LIMIT 1").Scan(&status); err != nil || status != "Hey there" {
+		t.Errorf("status = %q, %v", status, err)
+	}
+	if err := db.QueryRow("SELECT email, address FROM results WHERE phone = '15550000002' LIMIT 1").Scan(&email, &address); err != nil || email != "a@b.c" || address != "1 Main St" {
+		t.Errorf("business = %q, %q, %v", email, address, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// ScanSummary is the -summary report written once a scan ends, whether it
+// finished, was interrupted or hit -timeout or -max-found.
+type ScanSummary struct {
+	Patterns   []string  `json:"patterns"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Generated  int       `json:"generated"`
+	Cached     int       `json:"skipped_cached"`
+	Checked    int64     `json:"checked"`
+	Found      int       `json:"found"`
+	Errors     int64     `json:"errors"`
+	Rate       float64   `json:"rate_per_second"`
+	Status     string    `json:"status"`
+}
+
+func newScanSummary(patterns []string, start, end time.Time, generated int, checked int64, found int, errors int64, status string) ScanSummary {
+	duration := end.Sub(start)
+	rate := 0.0
+	if duration > 0 {
+		rate = float64(checked) / duration.Seconds()
+	}
+	return ScanSummary{
+		Patterns:   patterns,
+		StartedAt:  start.UTC(),
+		FinishedAt: end.UTC(),
+		DurationMs: duration.Milliseconds(),
+		Generated:  generated,
+		Checked:    checked,
+		Found:      found,
+		Errors:     errors,
+		Rate:       rate,
+		Status:     status,
+	}
+}
+
+func writeSummary(path string, summary ScanSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScanSummary(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	summary := newScanSummary([]string{"1555xx"}, start, start.Add(4*time.Second), 100, 80, 3, 2, "interrupted")
+	summary.Cached = 20
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if err := writeSummary(path, summary); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"patterns":        []any{"1555xx"},
+		"started_at":      "2024-05-01T10:00:00Z",
+		"finished_at":     "2024-05-01T10:00:04Z",
+		"duration_ms":     4000.0,
+		"generated":       100.0,
+		"skipped_cached":  20.0,
+		"checked":         80.0,
+		"found":           3.0,
+		"errors":          2.0,
+		"rate_per_second": 20.0,
+		"status":          "interrupted",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+}
+
+func TestScanSummaryZeroDuration(t *testing.T) {
+	now := time.Now()
+	if summary := newScanSummary(nil, now, now, 0, 0, 0, 0, "finished"); summary.Rate != 0 {
+		t.Errorf("Rate = %v, want 0", summary.Rate)
+	}
+}
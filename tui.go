@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const tuiRefresh = 200 * time.Millisecond
+
+// TUI is the full-screen -tui display. Workers and the result loop only
+// update shared counters; the bubbletea program polls them on a timer so a
+// slow terminal never holds up the scan.
+type TUI struct {
+	program *tea.Program
+	done    chan struct{}
+	total   int
+	start   time.Time
+	checked int64
+
+	mu    sync.Mutex
+	found []ScanResult
+}
+
+type tuiState struct {
+	Total    int
+	Checked  int
+	Found    []ScanResult
+	Elapsed  time.Duration
+	Quitting bool
+}
+
+type tuiTickMsg struct{}
+
+type tuiDoneMsg struct{}
+
+type tuiModel struct {
+	ui       *TUI
+	onQuit   func()
+	width    int
+	height   int
+	quitting bool
+}
+
+// StartTUI takes over the terminal until Finish is called. onQuit is called
+// once when the user presses q (or Ctrl+C).
+func StartTUI(total int, onQuit func()) *TUI {
+	ui := &TUI{total: total, start: time.Now(), done: make(chan struct{})}
+	ui.program = tea.NewProgram(tuiModel{ui: ui, onQuit: onQuit, width: 80, height: 24}, tea.WithAltScreen())
+	go func() {
+		defer close(ui.done)
+		ui.program.Run()
+	}()
+	return ui
+}
+
+func (ui *TUI) Checked() {
+	atomic.AddInt64(&ui.checked, 1)
+}
+
+func (ui *TUI) Found(res ScanResult) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.found = append(ui.found, res)
+}
+
+// Finish closes the TUI and restores the terminal.
+func (ui *TUI) Finish() {
+	ui.program.Send(tuiDoneMsg{})
+	<-ui.done
+}
+
+func (ui *TUI) state(quitting bool) tuiState {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	return tuiState{
+		Total:    ui.total,
+		Checked:  int(atomic.LoadInt64(&ui.checked)),
+		Found:    append([]ScanResult(nil), ui.found...),
+		Elapsed:  time.Since(ui.start),
+		Quitting: quitting,
+	}
+}
+
+func tuiTick() tea.Cmd {
+	return tea.Tick(tuiRefresh, func(time.Time) tea.Msg { return tuiTickMsg{} })
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return tuiTick()
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			if !m.quitting {
+				m.quitting = true
+				m.onQuit()
+			}
+		}
+	case tuiTickMsg:
+		return m, tuiTick()
+	case tuiDoneMsg:
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m tuiModel) View() string {
+	return renderTUI(m.ui.state(m.quitting), m.width, m.height)
+}
+
+// renderTUI draws one frame: a header, the progress gauge and rate, and as
+// many of the most recent found accounts as fit in height.
+func renderTUI(s tuiState, width, height int) string {
+	var sb strings.Builder
+	sb.WriteString(truncateLine(fmt.Sprintf("WhatsApp Brute Forcer (Go) - %d numbers", s.Total), width) + "\n\n")
+
+	var bar strings.Builder
+	renderProgress(&bar, s.Checked, s.Total, len(s.Found), tuiETA(s))
+	sb.WriteString(truncateLine(bar.String(), width) + "\n")
+
+	rate := 0.0
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		rate = float64(s.Checked) / secs
+	}
+	sb.WriteString(truncateLine(fmt.Sprintf("Rate: %.1f/s | Elapsed: %s", rate, s.Elapsed.Round(time.Second)), width) + "\n\n")
+
+	sb.WriteString("Found accounts:\n")
+	// Header (6 lines) and footer (2 lines) are always shown.
+	rows := height - 8
+	if rows < 1 {
+		rows = 1
+	}
+	shown := s.Found
+	if len(shown) > rows {
+		shown = shown[len(shown)-rows:]
+	}
+	for _, res := range shown {
+		line := "  " + formatOutput(res.JID, *outputFormat)
+		if res.Name != "" {
+			line += "  " + res.Name
+		} else if res.VerifiedName != "" {
+			line += "  " + res.VerifiedName
+		}
+		if res.Status != "" {
+			line += "  (" + res.Status + ")"
+		}
+		sb.WriteString(truncateLine(line, width) + "\n")
+	}
+	for i := len(shown); i < rows; i++ {
+		sb.WriteString("\n")
+	}
+
+	if s.Quitting {
+		sb.WriteString("\nStopping, waiting for in-flight checks to finish...")
+	} else {
+		sb.WriteString("\nq: quit")
+	}
+	return sb.String()
+}
+
+func tuiETA(s tuiState) time.Duration {
+	if s.Checked == 0 || s.Elapsed <= 0 {
+		return 0
+	}
+	rate := float64(s.Checked) / s.Elapsed.Seconds()
+	return (time.Duration(float64(s.Total-s.Checked)/rate) * time.Second).Round(time.Second)
+}
+
+func truncateLine(line string, width int) string {
+	runes := []rune(line)
+	if width > 0 && len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
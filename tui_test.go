@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRenderTUI(t *testing.T) {
+	setFlag(t, outputFormat, "pn")
+	s := tuiState{
+		Total:   100,
+		Checked: 50,
+		Elapsed: 10 * time.Second,
+		Found: []ScanResult{
+			{JID: "15550000001@c.us"},
+			{JID: "15550000002@c.us", Name: "Alice"},
+			{JID: "15550000003@c.us", VerifiedName: "Acme", Status: "Open 9-5"},
+		},
+	}
+
+	frame := renderTUI(s, 80, 10)
+	lines := strings.Split(frame, "\n")
+	if len(lines) != 10 {
+		t.Errorf("frame has %d lines, want the terminal height 10:\n%s", len(lines), frame)
+	}
+	for _, want := range []string{
+		"100 numbers",
+		" 50% 50/100 | Found: 3",
+		"Rate: 5.0/s",
+		"q: quit",
+		// Only the two most recent accounts fit in a 10-line terminal.
+		"  15550000002  Alice",
+		"  15550000003  Acme  (Open 9-5)",
+	} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame is missing %q:\n%s", want, frame)
+		}
+	}
+	if strings.Contains(frame, "15550000001") {
+		t.Errorf("frame shows an account that doesn't fit:\n%s", frame)
+	}
+
+	s.Quitting = true
+	if frame := renderTUI(s, 80, 10); !strings.Contains(frame, "Stopping") {
+		t.Errorf("quitting frame:\n%s", frame)
+	}
+}
+
+func TestRenderTUITruncatesLines(t *testing.T) {
+	s := tuiState{Total: 10, Found: []ScanResult{{JID: "15550000001@c.us", Name: strings.Repeat("x", 100)}}}
+	for _, line := range strings.Split(renderTUI(s, 30, 12), "\n") {
+		if n := len([]rune(line)); n > 30 {
+			t.Errorf("line is %d runes wide, want at most 30: %q", n, line)
+		}
+	}
+}
+
+func TestTUIQuitKey(t *testing.T) {
+	quits := 0
+	var m tea.Model = tuiModel{ui: &TUI{}, onQuit: func() { quits++ }}
+	for i := 0; i < 2; i++ {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	}
+	if quits != 1 {
+		t.Errorf("onQuit called %d times, want once", quits)
+	}
+	if !m.(tuiModel).quitting {
+		t.Error("model isn't quitting after q")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// readVCardNumbers returns every TEL number in a .vcf address book (as
+// exported by Google or Apple Contacts), normalized like a typed pattern,
+// along with the FN of the contact each number came from.
+func readVCardNumbers(path string) (numbers []string, names map[string]string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open vCard file: %v", err)
+	}
+	defer f.Close()
+
+	// Unfold continuation lines (RFC 6350 3.2) before parsing.
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	names = make(map[string]string)
+	seen := make(map[string]bool)
+	var name string
+	var tels []string
+	for _, line := range lines {
+		prop, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Apple exports group properties as item1.TEL;type=CELL:...
+		prop, _, _ = strings.Cut(prop, ";")
+		if i := strings.LastIndex(prop, "."); i >= 0 {
+			prop = prop[i+1:]
+		}
+		switch strings.ToUpper(prop) {
+		case "BEGIN":
+			name, tels = "", nil
+		case "FN":
+			name = strings.TrimSpace(value)
+		case "TEL":
+			tels = append(tels, value)
+		case "END":
+			for _, tel := range tels {
+				pn := vcardTelPattern(tel)
+				if pn == "" {
+					continue
+				}
+				if !seen[pn] {
+					seen[pn] = true
+					numbers = append(numbers, pn)
+				}
+				if _, ok := names[pn]; !ok && name != "" {
+					names[pn] = name
+				}
+			}
+			name, tels = "", nil
+		}
+	}
+	return numbers, names, nil
+}
+
+// vcardTelPattern turns a TEL value like "+1 (555) 123-4567 ext. 8" or
+// "tel:+1-555-123-4567" into a digits-only number, dropping any extension,
+// and applies -region to numbers without a leading +.
+func vcardTelPattern(tel string) string {
+	tel = strings.TrimSpace(tel)
+	tel = strings.TrimPrefix(strings.TrimPrefix(tel, "tel:"), "TEL:")
+	var sb strings.Builder
+	for i, r := range tel {
+		if unicode.IsLetter(r) || r == ';' || r == ',' {
+			break
+		}
+		if r == '+' && i == 0 || r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	if strings.Trim(sb.String(), "+") == "" {
+		return ""
+	}
+	return normalizePattern(sb.String())
+}
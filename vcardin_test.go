@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadVCardNumbers(t *testing.T) {
+	setFlag(t, region, "GB")
+	vcf := strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"FN:Alice Smith",
+		"item1.TEL;type=CELL:+1 (555) 000-0001",
+		"TEL;VALUE=uri:tel:+1-555-000-0002",
+		"END:VCARD",
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"FN:Bob",
+		"TEL;TYPE=work:+1 555 000",
+		" 0003 ext. 12",
+		"TEL:+1 555 000 0001",
+		"END:VCARD",
+		"BEGIN:VCARD",
+		"FN:Carol",
+		"TEL:07700 900123",
+		"TEL:",
+		"END:VCARD",
+		"BEGIN:VCARD",
+		"FN:No Phone",
+		"EMAIL:nobody@example.com",
+		"END:VCARD",
+	}, "\r\n")
+	path := filepath.Join(t.TempDir(), "contacts.vcf")
+	if err := os.WriteFile(path, []byte(vcf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	numbers, names, err := readVCardNumbers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNumbers := []string{"15550000001", "15550000002", "15550000003", "447700900123"}
+	if !equalStrings(numbers, wantNumbers) {
+		t.Errorf("numbers: got %v, want %v", numbers, wantNumbers)
+	}
+	wantNames := map[string]string{
+		// Bob shares Alice's first number; the first contact keeps it.
+		"15550000001":  "Alice Smith",
+		"15550000002":  "Alice Smith",
+		"15550000003":  "Bob",
+		"447700900123": "Carol",
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("names: got %v, want %v", names, wantNames)
+	}
+
+	if _, _, err := readVCardNumbers(filepath.Join(t.TempDir(), "missing.vcf")); err == nil {
+		t.Error("missing file should be an error")
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestFormatVCardFullResult(t *testing.T) {
+	avatar := filepath.Join(t.TempDir(), "15551234567.png")
+	f, err := os.Create(avatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 40, 40))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	data, _ := os.ReadFile(avatar)
+
+	res := ScanResult{
+		Phone:        "15551234567",
+		Name:         "Doe, Jane; CEO",
+		VerifiedName: `Acme\Co`,
+		Status:       "Out of office\nback Monday",
+		Type:         "business",
+		AvatarURL:    "https://pps.example/a.png",
+		AvatarPath:   avatar,
+		Business: &types.BusinessProfile{
+			Email:      "sales;eu@acme.example",
+			Address:    "1 Main St, Springfield",
+			Categories: []types.Category{{Name: "Food, Drink"}},
+		},
+	}
+	card := formatVCard(res)
+	// Unfold continuation lines before looking for PHOTO.
+	unfolded := strings.ReplaceAll(card, "\n ", "")
+
+	for _, want := range []string{
+		"BEGIN:VCARD\n",
+		`FN:Doe\, Jane\; CEO` + "\n",
+		"TEL;TYPE=CELL:+15551234567\n",
+		`ORG:Acme\\Co` + "\n",
+		"URL:https://pps.example/a.png\n",
+		`EMAIL:sales\;eu@acme.example` + "\n",
+		`ADR;TYPE=WORK:;;1 Main St\, Springfield;;;;` + "\n",
+		`NOTE:Out of office\nback Monday` + "\n",
+		`CATEGORIES:business,Food\, Drink` + "\n",
+		"PHOTO;ENCODING=b;TYPE=PNG:" + base64.StdEncoding.EncodeToString(data) + "\n",
+		"END:VCARD\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("card is missing %q:\n%s", want, card)
+		}
+	}
+	for _, line := range strings.Split(card, "\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+}
+
+func TestFormatVCardMinimal(t *testing.T) {
+	card := formatVCard(ScanResult{Phone: "15551234567"})
+	want := "BEGIN:VCARD\nVERSION:3.0\nFN:15551234567\nTEL;TYPE=CELL:+15551234567\nEND:VCARD\n"
+	if card != want {
+		t.Errorf("got\n%s\nwant\n%s", card, want)
+	}
+}
+
+func TestVCardPhotoType(t *testing.T) {
+	tests := map[string]string{
+		"\xff\xd8\xff\xe0\x00\x10JFIF\x00": "JPEG",
+		"\x89PNG\r\n\x1a\n":                "PNG",
+		"GIF89a":                           "GIF",
+		"RIFF\x00\x00\x00\x00WEBPVP8 ":     "WEBP",
+	}
+	for data, want := range tests {
+		if got := vcardPhotoType([]byte(data)); got != want {
+			t.Errorf("vcardPhotoType(%q) = %s, want %s", data, got, want)
+		}
+	}
+}
+
+func TestReadPatternsFromPipe(t *testing.T) {
+	input := "# numbers to check\n+1 555 000 0001\n1555000000x  # block\n\n15550000001\n"
+	patterns, opts, err := readPatterns(strings.NewReader(input), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"15550000001", "1555000000x", "15550000001"}
+	if !equalStrings(patterns, want) || len(opts) != len(want) {
+		t.Errorf("got %v, want %v", patterns, want)
+	}
+
+	// Duplicates across lines are dropped when the JIDs are generated.
+	n := 0
+	streamJIDs(patterns, func(string) bool {
+		n++
+		return true
+	})
+	if n != 10 {
+		t.Errorf("generated %d JIDs, want 10", n)
+	}
+
+	if _, _, err := readPatterns(strings.NewReader("1555\nnot-a-number\n"), "stdin"); err == nil || !strings.Contains(err.Error(), "stdin:2:") {
+		t.Errorf("err = %v, want one naming stdin:2", err)
+	}
+}
+
+func TestHasPatternSource(t *testing.T) {
+	if hasPatternSource() {
+		t.Fatal("no pattern flags set")
+	}
+	for name, set := range map[string]func(t *testing.T){
+		"input-file":    func(t *testing.T) { setFlag(t, inputFile, "blocks.txt") },
+		"vcard-in":      func(t *testing.T) { setFlag(t, vcardIn, "contacts.vcf") },
+		"range":         func(t *testing.T) { setFlag(t, numRange, "100..199") },
+		"reset":         func(t *testing.T) { setFlag(t, reset, true) },
+		"contacts-only": func(t *testing.T) { setFlag(t, contactsOnly, true) },
+		"serve":         func(t *testing.T) { setFlag(t, serveAddr, ":8080") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			set(t)
+			if !hasPatternSource() {
+				t.Errorf("-%s should keep stdin from being read", name)
+			}
+		})
+	}
+}
+
+var formatTests = []struct {
+	format string
+	want   string
+}{
+	{"wa.me", "https://wa.me/15551234567"},
+	{"jid", "15551234567@c.us"},
+	{"pn", "15551234567"},
+	{"e164", "+15551234567"},
+	{"tel", "tel:+15551234567"},
+	{"pretty", "+1 555-123-4567"},
+}
+
+func TestFormatOutput(t *testing.T) {
+	for _, tt := range formatTests {
+		if got := formatOutput("15551234567@c.us", tt.format); got != tt.want {
+			t.Errorf("formatOutput(%s) = %q, want %q", tt.format, got, tt.want)
+		}
+		if !isValidFormat(tt.format) {
+			t.Errorf("%s should be a valid -output-format", tt.format)
+		}
+	}
+	if isValidFormat("link") {
+		t.Error("unknown formats should be rejected")
+	}
+	if len(formatTests) != len(outputFormats) {
+		t.Errorf("%d formats tested, %d supported", len(formatTests), len(outputFormats))
+	}
+}
+
+func TestFormatResultUsesOutputFormat(t *testing.T) {
+	setFlag(t, outputFormat, "pn")
+	res := ScanResult{JID: "15551234567@c.us", Link: "https://wa.me/15551234567"}
+	if got := formatResult(res); got != "15551234567" {
+		t.Errorf("formatResult = %q, want the pn format instead of the link", got)
+	}
+}
+
+func TestFormatResultTemplate(t *testing.T) {
+	setFlag(t, &resultTemplate, template.Must(template.New("result").Parse("{{.Phone}},{{.Name}},{{.Link}}")))
+	res := ScanResult{JID: "15551234567@c.us", Phone: "15551234567", Name: "Alice", Link: "https://wa.me/15551234567"}
+	if got, want := formatResult(res), "15551234567,Alice,https://wa.me/15551234567"; got != want {
+		t.Errorf("formatResult = %q, want %q", got, want)
+	}
+}
+
+func TestInvalidTemplate(t *testing.T) {
+	for _, tmpl := range []string{"{{.Phone", "{{.NoSuchField}}", "{{.Business.NoSuchField}}"} {
+		if code := runArgs(t, "-dry-run", "-template", tmpl, "15551234567"); code != exitUsage {
+			t.Errorf("-template %q: exit code %d, want %d", tmpl, code, exitUsage)
+		}
+	}
+}
+
+// runArgs runs wabf with args as its command line and returns the exit
+// code. Flags start from their defaults and, like the globals run sets, are
+// restored afterwards.
+func runArgs(t *testing.T, args ...string) int {
+	t.Helper()
+	setFlag(t, &os.Args, append([]string{"wabf"}, args...))
+	setFlag(t, &console, console)
+	setFlag(t, &resultTemplate, resultTemplate)
+	setFlag(t, &httpClient, httpClient)
+	resetFlags()
+	t.Cleanup(resetFlags)
+	setFlag(t, &flag.CommandLine, freshCommandLine())
+	return run()
+}
+
+// freshCommandLine rebinds every flag to a new FlagSet, so flags given in
+// an earlier run don't count as set on this one's command line.
+func freshCommandLine() *flag.FlagSet {
+	fs := flag.NewFlagSet(flag.CommandLine.Name(), flag.ExitOnError)
+	fs.Usage = func() { flag.Usage() }
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	return fs
+}
+
+func resetFlags() {
+	flag.VisitAll(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "test.") {
+			f.Value.Set(f.DefValue)
+		}
+	})
+}
+
+func TestDryRunDoesNotConnect(t *testing.T) {
+	dir := t.TempDir()
+	session := filepath.Join(dir, "wabf.db")
+	out := filepath.Join(dir, "numbers.txt")
+	code := runArgs(t, "-dry-run", "-session", session, "-output-file", out, "-output-format", "pn", "1555000000x")
+	if code != exitOK {
+		t.Fatalf("exit code %d", code)
+	}
+	if _, err := os.Stat(session); !os.IsNotExist(err) {
+		t.Errorf("dry run opened the session database (%v)", err)
+	}
+	data, _ := os.ReadFile(out)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 10 || lines[0] != "15550000000" || lines[9] != "15550000009" {
+		t.Errorf("dry run wrote %q", data)
+	}
+}
+
+// dryRunNumbers runs a -dry-run with args and returns the numbers it wrote.
+func dryRunNumbers(t *testing.T, args ...string) []string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "numbers.txt")
+	args = append([]string{"-dry-run", "-output-file", out, "-output-format", "pn"}, args...)
+	if code := runArgs(t, args...); code != exitOK {
+		t.Fatalf("%v: exit code %d", args, code)
+	}
+	data, _ := os.ReadFile(out)
+	return strings.Fields(string(data))
+}
+
+func TestMultiplePatternArgs(t *testing.T) {
+	got := dryRunNumbers(t, "1555000000[12]", "+1 555 000 000[23]")
+	if want := []string{"15550000001", "15550000002", "15550000003"}; !equalStrings(got, want) {
+		t.Errorf("separate patterns: got %v, want %v", got, want)
+	}
+	got = dryRunNumbers(t, "-join", "1555", "000000[12]")
+	if want := []string{"15550000001", "15550000002"}; !equalStrings(got, want) {
+		t.Errorf("-join: got %v, want %v", got, want)
+	}
+}
+
+// captureOutput runs fn and returns what it wrote to stdout and stderr.
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	read := func(f **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := *f
+		*f = w
+		done := make(chan string)
+		go func() {
+			data, _ := io.ReadAll(r)
+			done <- string(data)
+		}()
+		return func() string {
+			*f = orig
+			w.Close()
+			return <-done
+		}
+	}
+	stopOut, stopErr := read(&os.Stdout), read(&os.Stderr)
+	fn()
+	return stopOut(), stopErr()
+}
+
+func TestQuiet(t *testing.T) {
+	stdout, stderr := captureOutput(t, func() {
+		if code := runArgs(t, "-quiet", "-dry-run", "-output-format", "e164", "1555000000[12]"); code != exitOK {
+			t.Errorf("exit code %d", code)
+		}
+	})
+	if want := "+15550000001\n+15550000002\n"; stdout != want {
+		t.Errorf("stdout:\ngot  %q\nwant %q", stdout, want)
+	}
+	if console != io.Discard {
+		t.Error("-quiet didn't silence the console")
+	}
+	if !strings.Contains(stderr, "2 numbers") {
+		t.Errorf("stderr = %q", stderr)
+	}
+
+	stdout, stderr = captureOutput(t, func() { runArgs(t, "-quiet", "-dry-run", "15abc") })
+	if stdout != "" || !strings.Contains(stderr, "15abc") {
+		t.Errorf("an error went to stdout %q instead of stderr %q", stdout, stderr)
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	var out strings.Builder
+	setFlag(t, &console, io.Writer(&out))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := make(chan os.Signal)
+	exited := make(chan int, 1)
+
+	_, stderr := captureOutput(t, func() {
+		go handleSignals(c, cancel, func(code int) { exited <- code })
+
+		c <- os.Interrupt
+		<-ctx.Done()
+		if !strings.Contains(out.String(), "press Ctrl+C again") {
+			t.Errorf("first interrupt printed %q", out.String())
+		}
+		select {
+		case code := <-exited:
+			t.Fatalf("exited with %d on the first interrupt", code)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		c <- os.Interrupt
+		select {
+		case code := <-exited:
+			if code != 130 {
+				t.Errorf("exit code %d, want 130", code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("second interrupt didn't exit")
+		}
+	})
+	if !strings.Contains(stderr, "Interrupted again") {
+		t.Errorf("stderr = %q", stderr)
+	}
+}
+
+func TestSortResults(t *testing.T) {
+	results := []ScanResult{
+		{Phone: "15550000003", Name: "bob", Status: "Busy"},
+		{Phone: "+15550000001", Status: "available"},
+		{Phone: "15550000004", Name: "Alice"},
+		{Phone: "15550000002", Name: "alice", Status: "Away"},
+	}
+	phones := func(results []ScanResult) []string {
+		var out []string
+		for _, res := range results {
+			out = append(out, strings.TrimPrefix(res.Phone, "+"))
+		}
+		return out
+	}
+	tests := []struct {
+		field string
+		want  []string
+	}{
+		{"phone", []string{"15550000001", "15550000002", "15550000003", "15550000004"}},
+		// Equal names fall back to phone order; no name sorts last.
+		{"name", []string{"15550000002", "15550000004", "15550000003", "15550000001"}},
+		{"status", []string{"15550000001", "15550000002", "15550000003", "15550000004"}},
+	}
+	for _, tt := range tests {
+		sorted := append([]ScanResult(nil), results...)
+		sortResults(sorted, tt.field)
+		if got := phones(sorted); !equalStrings(got, tt.want) {
+			t.Errorf("-sort %s: got %v, want %v", tt.field, got, tt.want)
+		}
+		if !isValidSortField(tt.field) {
+			t.Errorf("%s isn't a valid sort field", tt.field)
+		}
+	}
+	if isValidSortField("country") {
+		t.Error("country is a valid sort field")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	webhookQueueSize = 256
+	webhookRetries   = 2
+)
+
+// Webhook POSTs each result as JSON from its own goroutine so a slow
+// endpoint never holds up the scan.
+type Webhook struct {
+	url     string
+	client  *http.Client
+	backoff time.Duration
+	queue   chan ScanResult
+	wg      sync.WaitGroup
+	dropped int64
+}
+
+func NewWebhook(url string, client *http.Client, queueSize int) *Webhook {
+	w := &Webhook{
+		url:     url,
+		client:  client,
+		backoff: time.Second,
+		queue:   make(chan ScanResult, max(queueSize, 1)),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+// Send queues a result for delivery. If the queue is full the result is
+// dropped rather than blocking the caller; drops are counted, and the first
+// one is always reported.
+func (w *Webhook) Send(res ScanResult) {
+	select {
+	case w.queue <- res:
+	default:
+		metrics.Dropped.WithLabelValues("webhook").Inc()
+		if atomic.AddInt64(&w.dropped, 1) == 1 {
+			printWarning("Webhook queue full (%d results), dropping results until it catches up; raise -webhook-queue to keep them\n", cap(w.queue))
+		}
+		if *verbose {
+			slog.Warn("Webhook queue full, dropping result", "event", "webhook", "phone", res.Phone)
+		}
+	}
+}
+
+// Dropped returns how many results didn't fit in the queue.
+func (w *Webhook) Dropped() int64 {
+	return atomic.LoadInt64(&w.dropped)
+}
+
+func (w *Webhook) run() {
+	defer w.wg.Done()
+	for res := range w.queue {
+		if err := w.deliver(res); err != nil && *verbose {
+			slog.Warn("Webhook delivery failed", "event", "webhook", "phone", res.Phone, "error", err)
+		}
+	}
+}
+
+func (w *Webhook) deliver(res ScanResult) error {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(body)
+		if err == nil || attempt >= webhookRetries {
+			return err
+		}
+		if _, permanent := err.(webhookStatusError); permanent {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+type webhookStatusError struct {
+	status string
+}
+
+func (e webhookStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.status)
+}
+
+func (w *Webhook) post(body []byte) error {
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("server error %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return webhookStatusError{resp.Status}
+	}
+	return nil
+}
+
+// Close waits for every queued result to be delivered.
+func (w *Webhook) Close() error {
+	close(w.queue)
+	w.wg.Wait()
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mau.fi/whatsmeow/types"
+)
+
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	bodies   [][]byte
+	statuses []int
+}
+
+// newWebhookServer answers with statuses in order, then 200.
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" || r.Method != http.MethodPost {
+			t.Errorf("%s with Content-Type %q", r.Method, ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.bodies = append(s.bodies, body)
+		if len(s.statuses) > 0 {
+			w.WriteHeader(s.statuses[0])
+			s.statuses = s.statuses[1:]
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestWebhook(url string) *Webhook {
+	w := NewWebhook(url, &http.Client{Timeout: 5 * time.Second}, webhookQueueSize)
+	w.backoff = time.Millisecond
+	return w
+}
+
+func TestWebhookPayload(t *testing.T) {
+	srv := newWebhookServer(t)
+	w := newTestWebhook(srv.URL)
+	w.Send(ScanResult{Phone: "15550000001", JID: "15550000001@c.us", Link: "https://wa.me/15550000001", Found: true,
+		Status: "Hey there", Business: &types.BusinessProfile{Email: "a@b.c"}})
+	w.Close()
+
+	if len(srv.bodies) != 1 {
+		t.Fatalf("got %d requests", len(srv.bodies))
+	}
+	var got map[string]any
+	if err := json.Unmarshal(srv.bodies[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{"phone": "15550000001", "jid": "15550000001@c.us", "link": "https://wa.me/15550000001", "found": true, "status": "Hey there"} {
+		if got[key] != want {
+			t.Errorf("%s = %v, want %v", key, got[key], want)
+		}
+	}
+	if biz, ok := got["business"].(map[string]any); !ok || biz["Email"] != "a@b.c" {
+		t.Errorf("business = %v", got["business"])
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+	}{
+		{"server errors then success", []int{500, 503}, 3},
+		{"gives up after retries", []int{500, 500, 500, 500}, 1 + webhookRetries},
+		{"client errors aren't retried", []int{404}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newWebhookServer(t, tt.statuses...)
+			w := newTestWebhook(srv.URL)
+			w.Send(ScanResult{Phone: "15550000001", Found: true})
+			w.Close()
+			if len(srv.bodies) != tt.requests {
+				t.Errorf("got %d requests, want %d", len(srv.bodies), tt.requests)
+			}
+		})
+	}
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case arrived <- struct{}{}:
+		default:
+		}
+		<-release
+	}))
+	defer ts.Close()
+	before := testutil.ToFloat64(metrics.Dropped.WithLabelValues("webhook"))
+
+	w := NewWebhook(ts.URL, &http.Client{Timeout: 5 * time.Second}, 2)
+	_, stderr := captureOutput(t, func() {
+		// The first result is being posted, the next two fill the queue
+		// and the last two don't fit.
+		w.Send(ScanResult{Phone: "15550000001"})
+		<-arrived
+		for _, pn := range []string{"15550000002", "15550000003", "15550000004", "15550000005"} {
+			w.Send(ScanResult{Phone: pn})
+		}
+		close(release)
+		w.Close()
+	})
+
+	if got := w.Dropped(); got != 2 {
+		t.Errorf("Dropped = %d, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.Dropped.WithLabelValues("webhook")) - before; got != 2 {
+		t.Errorf("wabf_results_dropped_total{sink=webhook} went up by %v, want 2", got)
+	}
+	// Reported once, without -verbose.
+	if n := strings.Count(stderr, "Webhook queue full"); n != 1 || !strings.Contains(stderr, "-webhook-queue") {
+		t.Errorf("stderr = %q, want one warning", stderr)
+	}
+}
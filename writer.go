@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+)
+
+// ResultWriter owns every per-result export (-output-file, -csv, -vcard,
+// -xlsx, -sqlite-out, -json, -json-file, -webhook, -on-found). Results are
+// handed over a channel to a single goroutine, so nothing else ever touches
+// the handles and they are flushed and closed in one place.
+type ResultWriter struct {
+	results chan ScanResult
+	done    chan struct{}
+	closers []io.Closer
+	// flushers are the buffered (.gz) files, -json-file and the SQLite
+	// export, flushed with the CSV writer every flushEvery results.
+	flushers   []flusher
+	flushEvery int
+	unflushed  int
+	afterWrite func(res ScanResult)
+
+	text    io.Writer
+	csv     *csv.Writer
+	vcard   io.Writer
+	xlsx    *XLSXWriter
+	db      *ResultDB
+	json    *json.Encoder
+	array   *JSONArrayWriter
+	webhook *Webhook
+	hook    *Hook
+}
+
+// OpenResultWriter opens the exports selected by flags and starts the
+// writer goroutine. On error, anything already opened is closed again.
+func OpenResultWriter(proxyURL *url.URL) (w *ResultWriter, err error) {
+	w = &ResultWriter{
+		results:    make(chan ScanResult, enrichQueueSize),
+		done:       make(chan struct{}),
+		flushEvery: *flushEvery,
+	}
+	defer func() {
+		if err != nil {
+			w.closeAll()
+		}
+	}()
+
+	if *outputFile != "" {
+		if *verbose {
+			slog.Info("Opening output file", "event", "output", "path", *outputFile)
+		}
+		f, _, err := openOutput(*outputFile, *appendOut)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %v", err)
+		}
+		w.closers = append(w.closers, f)
+		w.addFlusher(f)
+		w.text = f
+	}
+
+	if *csvFile != "" {
+		f, empty, err := openOutput(*csvFile, *appendOut || *resumeCSV)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CSV file: %v", err)
+		}
+		w.closers = append(w.closers, f)
+		w.addFlusher(f)
+		w.csv = csv.NewWriter(f)
+		if empty {
+			w.csv.Write(csvHeader)
+		}
+	}
+
+	if *vcardFile != "" {
+		f, err := os.Create(*vcardFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create VCard file: %v", err)
+		}
+		w.closers = append(w.closers, f)
+		w.vcard = f
+	}
+
+	if *xlsxFile != "" {
+		w.xlsx, err = NewXLSXWriter(*xlsxFile, *saveAvatars, *sortField)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create XLSX file: %v", err)
+		}
+		w.closers = append(w.closers, w.xlsx)
+	}
+
+	if *sqliteOut != "" {
+		w.db, err = OpenResultDB(*sqliteOut)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open results database: %v", err)
+		}
+		w.closers = append(w.closers, w.db)
+		w.flushers = append(w.flushers, w.db)
+	}
+
+	if *jsonFile == "-" {
+		w.json = json.NewEncoder(os.Stdout)
+	} else if *jsonFile != "" {
+		f, err := os.Create(*jsonFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create JSON file: %v", err)
+		}
+		w.closers = append(w.closers, f)
+		w.json = json.NewEncoder(f)
+	}
+
+	if *jsonArray != "" {
+		w.array, err = NewJSONArrayWriter(*jsonArray, *sortField)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create JSON file: %v", err)
+		}
+		w.closers = append(w.closers, w.array)
+		w.flushers = append(w.flushers, w.array)
+	}
+
+	if *webhookURL != "" {
+		w.webhook = NewWebhook(*webhookURL, newHTTPClient(proxyURL, *webhookTO, nil), *webhookQueue)
+		w.closers = append(w.closers, w.webhook)
+		if *verbose {
+			slog.Info("Sending results to webhook", "event", "webhook", "url", *webhookURL)
+		}
+	}
+
+	if *onFound != "" {
+		w.hook = NewHook(*onFound, hookWorkers, *onFoundQueue)
+		w.closers = append(w.closers, w.hook)
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// AfterWrite sets fn to be called from the writer goroutine once each
+// result has been written and flushed, e.g. to checkpoint it only when it
+// can no longer be lost. Call it before the first Write.
+func (w *ResultWriter) AfterWrite(fn func(res ScanResult)) {
+	w.afterWrite = fn
+}
+
+// Dropped returns how many results the webhook and the -on-found hook had
+// to drop because their queues were full.
+func (w *ResultWriter) Dropped() (webhook, hook int64) {
+	if w.webhook != nil {
+		webhook = w.webhook.Dropped()
+	}
+	if w.hook != nil {
+		hook = w.hook.Dropped()
+	}
+	return webhook, hook
+}
+
+// Write queues res for every open export.
+func (w *ResultWriter) Write(res ScanResult) {
+	w.results <- res
+}
+
+// Close waits for queued results to be written, then flushes and closes
+// every export. It returns the first error seen.
+func (w *ResultWriter) Close() error {
+	close(w.results)
+	<-w.done
+	var err error
+	if w.csv != nil {
+		w.csv.Flush()
+		if csvErr := w.csv.Error(); csvErr != nil {
+			err = fmt.Errorf("failed to write CSV file: %v", csvErr)
+		}
+	}
+	if closeErr := w.closeAll(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (w *ResultWriter) closeAll() error {
+	var err error
+	for i := len(w.closers) - 1; i >= 0; i-- {
+		if closeErr := w.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	w.closers = nil
+	return err
+}
+
+type flusher interface {
+	Flush() error
+}
+
+func (w *ResultWriter) addFlusher(f io.Writer) {
+	if fl, ok := f.(flusher); ok {
+		w.flushers = append(w.flushers, fl)
+	}
+}
+
+func (w *ResultWriter) run() {
+	defer close(w.done)
+	for res := range w.results {
+		w.write(res)
+		if w.afterWrite != nil {
+			w.flush()
+			w.unflushed = 0
+			w.afterWrite(res)
+			continue
+		}
+		if w.flushEvery > 0 {
+			w.unflushed++
+			if w.unflushed >= w.flushEvery {
+				w.flush()
+				w.unflushed = 0
+			}
+		}
+	}
+}
+
+// flush pushes buffered rows to the files, so they survive a crash.
+func (w *ResultWriter) flush() {
+	if w.csv != nil {
+		w.csv.Flush()
+	}
+	for _, f := range w.flushers {
+		if err := f.Flush(); err != nil && *verbose {
+			slog.Warn("Failed to flush output", "event", "export", "error", err)
+		}
+	}
+}
+
+func (w *ResultWriter) write(res ScanResult) {
+	if w.csv != nil {
+		w.csv.Write(csvRow(res))
+	}
+
+	if w.xlsx != nil {
+		if err := w.xlsx.Write(res); err != nil && *verbose {
+			slog.Warn("Failed to write XLSX row", "event", "export", "phone", res.Phone, "error", err)
+		}
+	}
+
+	if w.json != nil {
+		if err := w.json.Encode(res); err != nil && *verbose {
+			slog.Warn("Failed to write JSON result", "event", "export", "phone", res.Phone, "error", err)
+		}
+	}
+
+	if w.array != nil {
+		if err := w.array.Write(res); err != nil && *verbose {
+			slog.Warn("Failed to write JSON file result", "event", "export", "phone", res.Phone, "error", err)
+		}
+	}
+
+	// Numbers not on WhatsApp (-include-not-found) only go to the tabular
+	// exports above.
+	if !res.Found {
+		return
+	}
+
+	if w.text != nil {
+		fmt.Fprintln(w.text, formatResult(res))
+	}
+
+	if w.db != nil {
+		if err := w.db.Insert(res); err != nil && *verbose {
+			slog.Warn("Failed to insert result into database", "event", "export", "phone", res.Phone, "error", err)
+		}
+	}
+
+	if w.webhook != nil {
+		w.webhook.Send(res)
+	}
+
+	if w.hook != nil {
+		w.hook.Send(res)
+	}
+
+	if w.vcard != nil {
+		io.WriteString(w.vcard, formatVCard(res))
+	}
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// setFlag sets a flag variable for the duration of the test.
+func setFlag[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestResultWriterFlushEvery(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "out.csv")
+	dbPath := filepath.Join(dir, "out.db")
+	jsonPath := filepath.Join(dir, "out.json.gz")
+	setFlag(t, csvFile, csvPath)
+	setFlag(t, sqliteOut, dbPath)
+	setFlag(t, jsonArray, jsonPath)
+	setFlag(t, flushEvery, 1)
+
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write(ScanResult{Phone: "15550000001", JID: "15550000001@c.us", Found: true})
+	w.Write(ScanResult{Phone: "15550000002", JID: "15550000002@c.us", Found: true})
+
+	// Both rows must be readable while the writer is still open.
+	waitFor(t, "CSV rows", func() bool {
+		data, _ := os.ReadFile(csvPath)
+		return strings.Count(string(data), "\n") == 3
+	})
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	waitFor(t, "SQLite rows", func() bool {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM results").Scan(&n)
+		return n == 2
+	})
+	// The array isn't closed yet, but both objects are in it.
+	waitFor(t, "JSON file results", func() bool {
+		f, err := os.Open(jsonPath)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return false
+		}
+		data, _ := io.ReadAll(zr)
+		return strings.HasPrefix(string(data), "[") &&
+			strings.Contains(string(data), "15550000001") && strings.Contains(string(data), "15550000002")
+	})
+}
+
+func TestResultWriterBuffersWithoutFlushEvery(t *testing.T) {
+	csvPath := filepath.Join(t.TempDir(), "out.csv")
+	setFlag(t, csvFile, csvPath)
+	setFlag(t, flushEvery, 0)
+
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(ScanResult{Phone: "15550000001", Found: true})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), strings.Join(csvHeader, ",")+"\n15550000001,") {
+		t.Errorf("unexpected CSV:\n%s", data)
+	}
+}
+
+func TestResultWriterNotFoundOnlyTabular(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "out.csv")
+	textPath := filepath.Join(dir, "out.txt")
+	setFlag(t, csvFile, csvPath)
+	setFlag(t, outputFile, textPath)
+
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(ScanResult{Phone: "15550000001", JID: "15550000001@c.us", Found: true})
+	w.Write(ScanResult{Phone: "15550000002", JID: "15550000002@c.us"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(csvPath)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], ",true") || !strings.HasSuffix(lines[2], ",false") {
+		t.Errorf("CSV should have both rows with their Found column:\n%s", data)
+	}
+	text, _ := os.ReadFile(textPath)
+	if strings.Contains(string(text), "15550000002") || !strings.Contains(string(text), "15550000001") {
+		t.Errorf("text output should only list found accounts:\n%s", text)
+	}
+}
+
+// TestResultWriterConcurrent is meant for go test -race: many goroutines
+// hand results to one writer that owns every export.
+func TestResultWriterConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	setFlag(t, outputFile, filepath.Join(dir, "out.txt"))
+	setFlag(t, csvFile, filepath.Join(dir, "out.csv"))
+	setFlag(t, vcardFile, filepath.Join(dir, "out.vcf"))
+	setFlag(t, sqliteOut, filepath.Join(dir, "out.db"))
+	setFlag(t, flushEvery, 7)
+
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const workers, perWorker = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				pn := fmt.Sprintf("1555%03d%04d", i, j)
+				w.Write(ScanResult{Phone: pn, JID: pn + "@c.us", Found: true})
+			}
+		}()
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const total = workers * perWorker
+	text, _ := os.ReadFile(*outputFile)
+	if n := strings.Count(string(text), "\n"); n != total {
+		t.Errorf("output file has %d lines, want %d", n, total)
+	}
+	rows, bad, err := loadCSVPhones(*csvFile)
+	if err != nil || bad != 0 || len(rows) != total {
+		t.Errorf("CSV has %d rows (%d bad, err %v), want %d", len(rows), bad, err, total)
+	}
+	vcf, _ := os.ReadFile(*vcardFile)
+	if n := strings.Count(string(vcf), "BEGIN:VCARD"); n != total {
+		t.Errorf("vCard file has %d cards, want %d", n, total)
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%s is not gzip: %v", path, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestResultWriterGzip(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "out.csv.gz")
+	textPath := filepath.Join(dir, "out.txt.GZ")
+	setFlag(t, csvFile, csvPath)
+	setFlag(t, outputFile, textPath)
+	setFlag(t, outputFormat, "pn")
+
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(ScanResult{Phone: "15550000001", JID: "15550000001@c.us", Found: true})
+	w.Write(ScanResult{Phone: "15550000002", JID: "15550000002@c.us", Found: true})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readGzip(t, textPath); got != "15550000001\n15550000002\n" {
+		t.Errorf("output file: %q", got)
+	}
+	rows, err := csv.NewReader(strings.NewReader(readGzip(t, csvPath))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(csvHeader, ",") || rows[2][0] != "15550000002" {
+		t.Errorf("CSV: %v", rows)
+	}
+}
+
+func TestResultWriterAppend(t *testing.T) {
+	for _, name := range []string{"out.csv", "out.csv.gz"} {
+		t.Run(name, func(t *testing.T) {
+			csvPath := filepath.Join(t.TempDir(), name)
+			setFlag(t, csvFile, csvPath)
+			setFlag(t, appendOut, true)
+
+			for _, phone := range []string{"15550000001", "15550000002"} {
+				if err := checkCSVHeader(csvPath); err != nil {
+					t.Fatal(err)
+				}
+				w, err := OpenResultWriter(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				w.Write(ScanResult{Phone: phone, JID: phone + "@c.us", Found: true})
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			done, bad, err := loadCSVPhones(csvPath)
+			if err != nil || bad != 0 {
+				t.Fatalf("loadCSVPhones: %v (%d bad rows)", err, bad)
+			}
+			if len(done) != 2 || !done["15550000001@c.us"] || !done["15550000002@c.us"] {
+				t.Errorf("rows from both runs should survive, got %v", done)
+			}
+		})
+	}
+
+	// The header is only written once.
+	csvPath := filepath.Join(t.TempDir(), "out.csv")
+	setFlag(t, csvFile, csvPath)
+	setFlag(t, appendOut, true)
+	for i := 0; i < 2; i++ {
+		w, err := OpenResultWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(ScanResult{Phone: "15550000001", Found: true})
+		w.Close()
+	}
+	data, _ := os.ReadFile(csvPath)
+	if n := strings.Count(string(data), "Phone,Link"); n != 1 {
+		t.Errorf("got %d headers:\n%s", n, data)
+	}
+}
+
+func TestCheckCSVHeader(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	current := strings.Join(csvHeader, ",") + "\n15550000001\n"
+	older := strings.Join(csvHeader[:len(csvHeader)-1], ",") + "\n15550000001\n"
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"missing", filepath.Join(dir, "missing.csv"), false},
+		{"empty", write("empty.csv", ""), false},
+		{"current", write("current.csv", current), false},
+		{"fewer columns", write("older.csv", older), true},
+		{"other columns", write("other.csv", "Phone,Name\n"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkCSVHeader(tt.path); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResultWriterNDJSON(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "out.ndjson")
+	setFlag(t, jsonFile, jsonPath)
+	setFlag(t, csvFile, filepath.Join(dir, "out.csv"))
+	setFlag(t, vcardFile, filepath.Join(dir, "out.vcf"))
+
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write(ScanResult{Phone: "15550000001", JID: "15550000001@c.us", Found: true})
+	w.Write(ScanResult{Phone: "15550000002", JID: "15550000002@c.us", Found: true, Business: &types.BusinessProfile{Email: "a@b.c"}})
+
+	// Each line is on disk as soon as it's written.
+	var lines []string
+	waitFor(t, "NDJSON lines", func() bool {
+		data, _ := os.ReadFile(jsonPath)
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		return len(lines) == 2
+	})
+	for i, line := range lines {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		biz, ok := obj["business"].(map[string]any)
+		if i == 0 && ok {
+			t.Errorf("business should be omitted when nil: %s", line)
+		}
+		if i == 1 && (!ok || biz["Email"] != "a@b.c") {
+			t.Errorf("business should be nested: %s", line)
+		}
+	}
+}
+
+// writeJSONArray writes results to path through a JSONArrayWriter.
+func writeJSONArray(t *testing.T, path, sortBy string, results []ScanResult) {
+	t.Helper()
+	j, err := NewJSONArrayWriter(path, sortBy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range results {
+		if err := j.Write(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONArrayWriter(t *testing.T) {
+	dir := t.TempDir()
+	results := []ScanResult{
+		{Phone: "15550000001", Found: true, AvatarURL: "https://pps.example/1.jpg", AvatarPath: "avatars/1.jpg"},
+		{Phone: "15550000002", Found: true, Business: &types.BusinessProfile{Email: "a@b.c", Address: "1 Main St"}},
+	}
+	for _, name := range []string{"out.json", "out.json.gz"} {
+		path := filepath.Join(dir, name)
+		writeJSONArray(t, path, "", results)
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var got []ScanResult
+		err = json.NewDecoder(r).Decode(&got)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s is not a valid JSON array: %v", name, err)
+		}
+		if len(got) != 2 || got[0].AvatarPath != "avatars/1.jpg" || got[1].Business == nil || got[1].Business.Address != "1 Main St" {
+			t.Errorf("%s: got %+v", name, got)
+		}
+	}
+
+	// Streamed or not, the file reads the same as the whole array indented.
+	data, _ := os.ReadFile(filepath.Join(dir, "out.json"))
+	want, _ := json.MarshalIndent(results, "", "  ")
+	if string(data) != string(want)+"\n" {
+		t.Errorf("streamed array:\n%s\nwant:\n%s", data, want)
+	}
+
+	// No results still gives a valid, empty array.
+	path := filepath.Join(dir, "empty.json")
+	writeJSONArray(t, path, "", nil)
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != "[]" {
+		t.Errorf("empty result set written as %q", data)
+	}
+
+	// With -sort, results are held back and written in order on Close.
+	path = filepath.Join(dir, "sorted.json")
+	writeJSONArray(t, path, "phone", []ScanResult{results[1], results[0]})
+	var got []ScanResult
+	data, _ = os.ReadFile(path)
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Phone != "15550000001" || got[1].Phone != "15550000002" {
+		t.Errorf("sorted array = %+v", got)
+	}
+}
+
+func TestBusinessHours(t *testing.T) {
+	biz := &types.BusinessProfile{
+		BusinessHoursTimeZone: "Europe/Berlin",
+		BusinessHours: []types.BusinessHoursConfig{
+			{DayOfWeek: "mon", Mode: "specific_hours", OpenTime: "540", CloseTime: "1050"},
+			{DayOfWeek: "sat", Mode: "open_24h"},
+			{DayOfWeek: "sun"},
+			{Mode: "specific_hours"},
+		},
+	}
+	if got, want := businessHours(biz), "mon 09:00-17:30; sat open_24h; sun (Europe/Berlin)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := businessHours(&types.BusinessProfile{BusinessHoursTimeZone: "UTC"}); got != "" {
+		t.Errorf("no hours: got %q", got)
+	}
+}
+
+func TestBusinessFieldsInExports(t *testing.T) {
+	dir := t.TempDir()
+	csvPath, jsonPath, vcfPath := filepath.Join(dir, "out.csv"), filepath.Join(dir, "out.ndjson"), filepath.Join(dir, "out.vcf")
+	setFlag(t, csvFile, csvPath)
+	setFlag(t, jsonFile, jsonPath)
+	setFlag(t, vcardFile, vcfPath)
+
+	w, err := OpenResultWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(ScanResult{Phone: "15550000001", Found: true, Business: &types.BusinessProfile{
+		Categories:    []types.Category{{ID: "1", Name: "Bakery"}, {ID: "2", Name: "Cafe"}},
+		BusinessHours: []types.BusinessHoursConfig{{DayOfWeek: "mon", Mode: "specific_hours", OpenTime: "420", CloseTime: "720"}},
+	}})
+	w.Write(ScanResult{Phone: "15550000002", Found: true})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := os.Open(csvPath)
+	rows, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("CSV: %v, %d rows", err, len(rows))
+	}
+	col := func(name string) int {
+		for i, h := range rows[0] {
+			if h == name {
+				return i
+			}
+		}
+		t.Fatalf("no %s column", name)
+		return -1
+	}
+	if got := rows[1][col("Category")]; got != "Bakery, Cafe" {
+		t.Errorf("CSV Category = %q", got)
+	}
+	if got := rows[1][col("BusinessHours")]; got != "mon 07:00-12:00" {
+		t.Errorf("CSV BusinessHours = %q", got)
+	}
+	if rows[2][col("Category")] != "" || rows[2][col("BusinessHours")] != "" {
+		t.Errorf("personal account has business columns: %v", rows[2])
+	}
+
+	data, _ := os.ReadFile(jsonPath)
+	first := strings.SplitN(string(data), "\n", 2)[0]
+	if !strings.Contains(first, `"Name":"Bakery"`) || !strings.Contains(first, `"OpenTime":"420"`) {
+		t.Errorf("JSON is missing business fields: %s", first)
+	}
+
+	vcf, _ := os.ReadFile(vcfPath)
+	if !strings.Contains(string(vcf), "CATEGORIES:Bakery,Cafe\n") || !strings.Contains(string(vcf), `NOTE:Business hours: mon 07:00-12:00`) {
+		t.Errorf("VCard is missing business fields:\n%s", vcf)
+	}
+}
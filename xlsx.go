@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "Results"
+
+type XLSXWriter struct {
+	f            *excelize.File
+	path         string
+	row          int
+	embedAvatars bool
+	sortBy       string
+	pending      []ScanResult
+}
+
+// NewXLSXWriter creates the workbook. With sortBy set, rows are held back
+// and written in sortResults order when the writer is closed.
+func NewXLSXWriter(path string, embedAvatars bool, sortBy string) (*XLSXWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return nil, err
+	}
+	header := make([]interface{}, 0, len(csvHeader)+1)
+	for _, h := range csvHeader {
+		header = append(header, h)
+	}
+	if embedAvatars {
+		header = append(header, "Avatar")
+	}
+	if err := f.SetSheetRow(xlsxSheet, "A1", &header); err != nil {
+		return nil, err
+	}
+	// Fail early if the destination isn't writable rather than after the scan.
+	out, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	return &XLSXWriter{f: f, path: path, row: 1, embedAvatars: embedAvatars, sortBy: sortBy}, nil
+}
+
+func (x *XLSXWriter) Write(res ScanResult) error {
+	if x.sortBy != "" {
+		x.pending = append(x.pending, res)
+		return nil
+	}
+	return x.writeRow(res)
+}
+
+func (x *XLSXWriter) writeRow(res ScanResult) error {
+	x.row++
+	fields := csvRow(res)
+	row := make([]interface{}, len(fields))
+	for i, v := range fields {
+		row[i] = v
+	}
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	if err := x.f.SetSheetRow(xlsxSheet, cell, &row); err != nil {
+		return err
+	}
+
+	if x.embedAvatars && res.AvatarPath != "" && !strings.HasSuffix(res.AvatarPath, ".webp") {
+		if _, err := os.Stat(res.AvatarPath); err == nil {
+			avatarCell, _ := excelize.CoordinatesToCellName(len(csvHeader)+1, x.row)
+			x.f.SetRowHeight(xlsxSheet, x.row, 60)
+			return x.f.AddPicture(xlsxSheet, avatarCell, res.AvatarPath, &excelize.GraphicOptions{
+				AutoFit:         true,
+				LockAspectRatio: true,
+			})
+		}
+	}
+	return nil
+}
+
+func (x *XLSXWriter) Close() error {
+	defer x.f.Close()
+	sortResults(x.pending, x.sortBy)
+	for _, res := range x.pending {
+		if err := x.writeRow(res); err != nil && *verbose {
+			slog.Warn("Failed to write XLSX row", "event", "export", "phone", res.Phone, "error", err)
+		}
+	}
+	return x.f.SaveAs(x.path)
+}
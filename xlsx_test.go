@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestXLSXWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.xlsx")
+	x, err := NewXLSXWriter(path, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := ScanResult{Phone: "15550000001", Link: "https://wa.me/15550000001", Status: "Hey there", Found: true}
+	if err := x.Write(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(xlsxSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want header and one result", len(rows))
+	}
+	if !equalStrings(rows[0], csvHeader) {
+		t.Errorf("header = %v", rows[0])
+	}
+	// Trailing empty cells aren't returned.
+	want := csvRow(res)
+	for i, cell := range rows[1] {
+		if cell != want[i] {
+			t.Errorf("column %s = %q, want %q", csvHeader[i], cell, want[i])
+		}
+	}
+	if rows[1][0] != "15550000001" || rows[1][2] != "Hey there" {
+		t.Errorf("first row = %v", rows[1])
+	}
+}
+
+func TestXLSXWriterSorted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.xlsx")
+	x, err := NewXLSXWriter(path, false, "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pn := range []string{"15550000003", "15550000001", "15550000002"} {
+		if err := x.Write(ScanResult{Phone: pn, Found: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(xlsxSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range rows[1:] {
+		got = append(got, row[0])
+	}
+	if want := []string{"15550000001", "15550000002", "15550000003"}; !equalStrings(got, want) {
+		t.Errorf("rows in order %v, want %v", got, want)
+	}
+}